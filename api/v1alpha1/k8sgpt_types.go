@@ -17,7 +17,11 @@ limitations under the License.
 package v1alpha1
 
 import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -45,16 +49,39 @@ type RemoteCacheRef struct {
 	GCS         *GCSBackend     `json:"gcs,omitempty"`
 	S3          *S3Backend      `json:"s3,omitempty"`
 	Azure       *AzureBackend   `json:"azure,omitempty"`
+	Redis       *RedisBackend   `json:"redis,omitempty"`
+	// EncryptionKey of the results stored in the remote cache
+	EncryptionKey *corev1.SecretKeySelector `json:"encryptionKey,omitempty"`
+	// TTL of the results in the remote cache, stale results are analyzed again
+	TTL *metav1.Duration `json:"ttl,omitempty"`
 }
 
 type S3Backend struct {
 	BucketName string `json:"bucketName,omitempty"`
 	Region     string `json:"region,omitempty"`
+	// Endpoint of an S3 compatible store, e.g. MinIO
+	Endpoint string `json:"endpoint,omitempty"`
+	// InsecureSkipVerify the TLS certificate of the endpoint
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+	// Prefix of the keys the results are stored under
+	Prefix string `json:"prefix,omitempty"`
 }
 
 type AzureBackend struct {
 	StorageAccount string `json:"storageAccount,omitempty"`
 	ContainerName  string `json:"containerName,omitempty"`
+	// BlobEndpoint of the storage account, e.g. in a sovereign cloud
+	BlobEndpoint string `json:"blobEndpoint,omitempty"`
+	// CloudEnvironment the storage account and credentials belong to
+	// +kubebuilder:validation:Enum=AzurePublic;AzureGovernment;AzureChina
+	CloudEnvironment string `json:"cloudEnvironment,omitempty"`
+}
+
+type RedisBackend struct {
+	Address string `json:"address,omitempty"`
+	// Credentials is the secret with the redis_password key, a redis without a
+	// password needs none
+	Credentials *corev1.LocalObjectReference `json:"credentials,omitempty"`
 }
 
 type GCSBackend struct {
@@ -71,18 +98,210 @@ type WebhookRef struct {
 
 type AISpec struct {
 	// +kubebuilder:default:=openai
-	// +kubebuilder:validation:Enum=openai;localai;azureopenai;amazonbedrock;cohere;amazonsagemaker
+	// +kubebuilder:validation:Enum=openai;localai;azureopenai;amazonbedrock;cohere;amazonsagemaker;anthropic;groq;mistral
 	Backend string `json:"backend"`
 	BaseUrl string `json:"baseUrl,omitempty"`
+	// BaseUrlSecretRef provides the base url from a secret instead of BaseUrl
+	BaseUrlSecretRef *corev1.SecretKeySelector `json:"baseUrlSecretRef,omitempty"`
 	// +kubebuilder:default:=gpt-3.5-turbo
-	Model   string     `json:"model,omitempty"`
-	Engine  string     `json:"engine,omitempty"`
-	Secret  *SecretRef `json:"secret,omitempty"`
-	Enabled bool       `json:"enabled,omitempty"`
+	Model  string `json:"model,omitempty"`
+	Engine string `json:"engine,omitempty"`
+	// APIVersion of the Azure OpenAI API, used by the azureopenai backend.
+	// k8sgpt uses its default api version if empty
+	APIVersion string `json:"apiVersion,omitempty"`
+	// AzureAD authenticates the azureopenai backend with an Azure AD identity
+	// instead of the API key of Secret
+	AzureAD *AzureADSpec `json:"azureAD,omitempty"`
+	Secret  *SecretRef   `json:"secret,omitempty"`
+	Enabled bool         `json:"enabled,omitempty"`
 	// +kubebuilder:default:=true
 	Anonymize bool `json:"anonymized,omitempty"`
+	// Language of the analysis. Languages other than SupportedLanguages are
+	// passed on to k8sgpt, but reported with a warning condition.
 	// +kubebuilder:default:=english
 	Language string `json:"language,omitempty"`
+	// CacheResults of the analysis in k8sgpt to avoid redundant calls to the backend,
+	// cannot be combined with spec.noCache
+	CacheResults bool `json:"cacheResults,omitempty"`
+	// ReasoningEffort of models supporting extended reasoning, e.g. OpenAI o1
+	// +kubebuilder:validation:Enum=low;medium;high
+	ReasoningEffort string `json:"reasoningEffort,omitempty"`
+	// ModelOverridePerAnalyzer maps analyzer names, e.g. Log, to the model used for them
+	ModelOverridePerAnalyzer map[string]string `json:"modelOverridePerAnalyzer,omitempty"`
+	// ContextWindow caps the size of the analysis context in tokens
+	// +kubebuilder:validation:Minimum=1000
+	// +kubebuilder:validation:Maximum=200000
+	ContextWindow int32 `json:"contextWindow,omitempty"`
+	// BatchSize of the resources k8sgpt analyzes at once
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	BatchSize int32 `json:"batchSize,omitempty"`
+	// FunctionCalling lets the model call tools, see FunctionCallingBackends
+	FunctionCalling bool `json:"functionCalling,omitempty"`
+	// OutputFormat of the analysis details
+	// +kubebuilder:validation:Enum=json;markdown;text
+	OutputFormat string `json:"outputFormat,omitempty"`
+	// TopP is the nucleus sampling parameter between 0.0 and 1.0, a string
+	// as floats are not portable in the API
+	// +kubebuilder:validation:Pattern=`^(0(\.[0-9]+)?|1(\.0+)?)$`
+	TopP *string `json:"topP,omitempty"`
+	// SeedValue makes the output of backends supporting a seed reproducible, for testing
+	SeedValue *int64 `json:"seedValue,omitempty"`
+	// DisableTelemetry of k8sgpt, e.g. in air-gapped clusters
+	// +kubebuilder:default:=false
+	DisableTelemetry bool `json:"disableTelemetry,omitempty"`
+	// BackendFallback are tried in order when the backend is unavailable
+	BackendFallback []AIBackend `json:"backendFallback,omitempty"`
+	// RetryPolicy of k8sgpt for transient errors of the backend
+	RetryPolicy *AIRetryPolicy `json:"retryPolicy,omitempty"`
+	// PromptTemplate of the per-resource analysis, it must contain the
+	// {{.ResourceName}} placeholder
+	PromptTemplate string `json:"promptTemplate,omitempty"`
+	// ExtraHeaders sent with every call to the backend, e.g. for API gateways
+	ExtraHeaders map[string]string `json:"extraHeaders,omitempty"`
+	// ExtraHeadersSecretRef provides sensitive headers, each key of the secret is
+	// a header. The secret must be in the namespace of the k8sgpt deployment.
+	ExtraHeadersSecretRef *corev1.SecretReference `json:"extraHeadersSecretRef,omitempty"`
+	// Plugins of k8sgpt and their configuration
+	Plugins []PluginSpec `json:"plugins,omitempty"`
+	// LocalModelPath of a model file, e.g. in llama.cpp format, mounted into the
+	// k8sgpt container. The file is taken from the same path on the node unless
+	// LocalModelClaimName is set, host paths are not allowed by the restricted
+	// Pod Security Standard.
+	LocalModelPath string `json:"localModelPath,omitempty"`
+	// LocalModelClaimName of a PersistentVolumeClaim holding the model file, it
+	// is mounted read-only at the directory of LocalModelPath
+	LocalModelClaimName string `json:"localModelClaimName,omitempty"`
+	// ConversationHistory keeps the context of previous analyses for the AI
+	// backend. The history is stored in the data volume of k8sgpt, an emptyDir
+	// without size limit, and is lost when the k8sgpt pod is replaced.
+	ConversationHistory bool `json:"conversationHistory,omitempty"`
+	// ResponseTimeout of the calls to the AI backend, at least 5s. Analyses of
+	// large clusters take longer and fail with a too short timeout.
+	ResponseTimeout *metav1.Duration `json:"responseTimeout,omitempty"`
+}
+
+type PluginSpec struct {
+	// Name of the plugin, it becomes part of the environment variables
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_]+$`
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled,omitempty"`
+	// Config of the plugin, each key is passed as a separate environment variable
+	Config map[string]string `json:"config,omitempty"`
+}
+
+type AzureADSpec struct {
+	// +kubebuilder:validation:MinLength=1
+	TenantID string `json:"tenantID"`
+	// +kubebuilder:validation:MinLength=1
+	ClientID string `json:"clientID"`
+	// ClientSecretRef is not needed with a managed identity
+	ClientSecretRef *corev1.SecretKeySelector `json:"clientSecretRef,omitempty"`
+}
+
+type AIRetryPolicy struct {
+	// +kubebuilder:default:=3
+	// +kubebuilder:validation:Minimum=0
+	MaxRetries int32 `json:"maxRetries,omitempty"`
+	// +kubebuilder:default:="1s"
+	RetryDelay *metav1.Duration `json:"retryDelay,omitempty"`
+}
+
+// +kubebuilder:validation:Enum=openai;localai;azureopenai;amazonbedrock;cohere;amazonsagemaker;anthropic;groq;mistral
+type AIBackend string
+
+type MonitoringSpec struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Interval at which the k8sgpt metrics are scraped
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ms|s|m|h))+$`
+	Interval string `json:"interval,omitempty"`
+	// PodMonitor scrapes the k8sgpt pod with a PodMonitor instead of a ServiceMonitor
+	PodMonitor bool `json:"podMonitor,omitempty"`
+}
+
+type AlertingRulesSpec struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// ResultsThreshold above which the alert fires
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum=0
+	ResultsThreshold int32 `json:"resultsThreshold,omitempty"`
+	// For how long the threshold must be exceeded before the alert fires
+	// +kubebuilder:default:="15m"
+	// +kubebuilder:validation:Pattern=`^([0-9]+(\.[0-9]+)?(ms|s|m|h))+$`
+	For string `json:"for,omitempty"`
+	// Severity label of the alert
+	// +kubebuilder:default:=warning
+	Severity string `json:"severity,omitempty"`
+}
+
+type EgressPolicySpec struct {
+	// AllowedCIDRs the k8sgpt pod may connect to, they must include the
+	// Kubernetes API server as well as the AI backend. Required.
+	AllowedCIDRs []string `json:"allowedCIDRs,omitempty"`
+	// AllowedPorts on the allowed CIDRs, any port if empty. They require AllowedCIDRs.
+	AllowedPorts []int32 `json:"allowedPorts,omitempty"`
+}
+
+type ServicePortSpec struct {
+	// Port of the k8sgpt Service, defaults to 8080
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+	// TargetPort k8sgpt listens on, a number or the name of the container port
+	TargetPort intstr.IntOrString `json:"targetPort,omitempty"`
+	// Protocol of the port, defaults to TCP
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+}
+
+type EphemeralContainerPolicySpec struct {
+	// Allowed grants k8sgpt access to the ephemeral containers of the analyzed pods
+	Allowed bool `json:"allowed,omitempty"`
+}
+
+type ObservabilitySpec struct {
+	// OTLPEndpoint the k8sgpt traces are exported to
+	OTLPEndpoint string `json:"otlpEndpoint,omitempty"`
+	// OTLPHeaders sent with every export, e.g. the tenant of the collector
+	OTLPHeaders map[string]string `json:"otlpHeaders,omitempty"`
+	// OTLPHeadersSecretRef provides the headers from a secret instead of OTLPHeaders,
+	// e.g. when they contain auth tokens. The key holds the headers as key=value pairs
+	// separated by commas.
+	OTLPHeadersSecretRef *corev1.SecretKeySelector `json:"otlpHeadersSecretRef,omitempty"`
+	// ServiceName of the k8sgpt traces
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+type RuntimeTuningSpec struct {
+	// GoDebug settings of the k8sgpt Go runtime, e.g. madvdontneed=1
+	GoDebug string `json:"goDebug,omitempty"`
+	// GoGC is the garbage collection target percentage of the k8sgpt Go runtime
+	// +kubebuilder:validation:Minimum=10
+	// +kubebuilder:validation:Maximum=1000
+	GoGC *int32 `json:"goGC,omitempty"`
+}
+
+type ScheduledAnalysisSpec struct {
+	// Schedule of the analysis in cron format
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+	// Suspend the scheduled analysis without removing it
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+type UpdatePolicySpec struct {
+	// AllowedHours the operator applies changes in, e.g. 02:00-04:00. A range
+	// ending before it starts spans midnight.
+	AllowedHours []string `json:"allowedHours,omitempty"`
+	// Timezone of the AllowedHours, e.g. Europe/Berlin, defaults to UTC
+	Timezone string `json:"timezone,omitempty"`
+}
+
+type VPASpec struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// UpdateMode of the VerticalPodAutoscaler
+	// +kubebuilder:validation:Enum=Off;Initial;Recreate;Auto
+	// +kubebuilder:default:=Auto
+	UpdateMode string `json:"updateMode,omitempty"`
 }
 
 type Trivy struct {
@@ -94,11 +313,22 @@ type Integrations struct {
 	Trivy *Trivy `json:"trivy,omitempty"`
 }
 
+type ImageSpec struct {
+	// +kubebuilder:default:=ghcr.io/k8sgpt-ai/k8sgpt
+	Repository string `json:"repository,omitempty"`
+	Version    string `json:"version,omitempty"`
+}
+
 // K8sGPTSpec defines the desired state of K8sGPT
 type K8sGPTSpec struct {
+	// Image of k8sgpt
+	Image *ImageSpec `json:"image,omitempty"`
+	// Deprecated: use Image.Version, the webhook moves it and later changes of it to Image
 	Version string `json:"version,omitempty"`
+	// Deprecated: use Image.Repository, the webhook moves it and later changes of it to Image
 	// +kubebuilder:default:=ghcr.io/k8sgpt-ai/k8sgpt
-	Repository   string           `json:"repository,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	// NoCache forces a fresh analysis by k8sgpt on every run
 	NoCache      bool             `json:"noCache,omitempty"`
 	Filters      []string         `json:"filters,omitempty"`
 	ExtraOptions *ExtraOptionsRef `json:"extraOptions,omitempty"`
@@ -106,6 +336,129 @@ type K8sGPTSpec struct {
 	AI           *AISpec          `json:"ai,omitempty"`
 	RemoteCache  *RemoteCacheRef  `json:"remoteCache,omitempty"`
 	Integrations *Integrations    `json:"integrations,omitempty"`
+	// UpdateStrategy of the k8sgpt deployment
+	UpdateStrategy appsv1.DeploymentStrategy `json:"updateStrategy,omitempty"`
+	// MaxSurge and MaxUnavailable tune the RollingUpdate strategy without
+	// having to specify the full UpdateStrategy
+	MaxSurge       *intstr.IntOrString `json:"maxSurge,omitempty"`
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// MinReadySeconds a new k8sgpt pod must be ready before it is considered available
+	// +kubebuilder:default:=0
+	// +kubebuilder:validation:Minimum=0
+	MinReadySeconds int32 `json:"minReadySeconds,omitempty"`
+	// ProgressDeadlineSeconds before a stalled k8sgpt rollout is reported as failed
+	// +kubebuilder:validation:Minimum=1
+	ProgressDeadlineSeconds *int32 `json:"progressDeadlineSeconds,omitempty"`
+	// Monitoring creates a ServiceMonitor for the Prometheus Operator
+	Monitoring *MonitoringSpec `json:"monitoring,omitempty"`
+	// ShareProcessNamespace between the containers of the k8sgpt pod, e.g. for debugging sidecars
+	// +kubebuilder:default:=false
+	ShareProcessNamespace *bool `json:"shareProcessNamespace,omitempty"`
+	// TargetNamespace the k8sgpt workload is deployed to, defaults to the namespace of the K8sGPT instance
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+	// EnvFrom populates the environment of the k8sgpt container from ConfigMaps or Secrets
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+	// RuntimeClassName of the k8sgpt pod, e.g. to run it with gVisor or Kata Containers
+	RuntimeClassName *string `json:"runtimeClassName,omitempty"`
+	// ExtraClusterRoleRules are appended to the rules of the k8sgpt ClusterRole
+	ExtraClusterRoleRules []rbacv1.PolicyRule `json:"extraClusterRoleRules,omitempty"`
+	// VerticalPodAutoscaler creates a VerticalPodAutoscaler for the k8sgpt deployment
+	VerticalPodAutoscaler *VPASpec `json:"verticalPodAutoscaler,omitempty"`
+	// HostNetwork runs the k8sgpt pod in the host network, bypassing network policies
+	HostNetwork bool `json:"hostNetwork,omitempty"`
+	// RevisionHistoryLimit of old ReplicaSets kept for the k8sgpt deployment
+	// +kubebuilder:validation:Minimum=0
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+	// AutomountServiceAccountToken can be disabled when k8sgpt authenticates
+	// otherwise, e.g. with Workload Identity or IRSA
+	// +kubebuilder:default:=true
+	AutomountServiceAccountToken *bool `json:"automountServiceAccountToken,omitempty"`
+	// ReadinessGates of the k8sgpt pod, e.g. for service meshes or gateway controllers
+	ReadinessGates []corev1.PodReadinessGate `json:"readinessGates,omitempty"`
+	// AlertingRules creates a PrometheusRule for the Prometheus Operator
+	AlertingRules *AlertingRulesSpec `json:"alertingRules,omitempty"`
+	// ContainerSecurityContext of the k8sgpt container, defaults to a non-root
+	// context complying with the restricted Pod Security Standard
+	ContainerSecurityContext *corev1.SecurityContext `json:"containerSecurityContext,omitempty"`
+	// EgressPolicy creates a NetworkPolicy restricting the egress of the k8sgpt pod
+	EgressPolicy *EgressPolicySpec `json:"egressPolicy,omitempty"`
+	// DenyIngressFromAllNamespaces but the one of the operator, with the same
+	// NetworkPolicy as the EgressPolicy. Metrics can then not be scraped from
+	// other namespaces.
+	DenyIngressFromAllNamespaces bool `json:"denyIngressFromAllNamespaces,omitempty"`
+	// ServiceAccountAnnotations of the k8sgpt ServiceAccount, e.g. for workload identity.
+	// Annotations added by others are kept, removed ones are not cleaned up
+	ServiceAccountAnnotations map[string]string `json:"serviceAccountAnnotations,omitempty"`
+	// WatchedNamespaces scope the analysis of k8sgpt, access to the resources is
+	// then granted by a Role in each namespace instead of the ClusterRole
+	WatchedNamespaces []string `json:"watchedNamespaces,omitempty"`
+	// HostAliases of the k8sgpt pod, e.g. for private AI endpoints missing in DNS
+	HostAliases []corev1.HostAlias `json:"hostAliases,omitempty"`
+	// WorkingDir of the k8sgpt container, defaults to the one of the image
+	// +kubebuilder:validation:Pattern=`^/`
+	WorkingDir string `json:"workingDir,omitempty"`
+	// SessionAffinity of the k8sgpt Service, ClientIP keeps clients on the same replica
+	// +kubebuilder:default:=None
+	// +kubebuilder:validation:Enum=None;ClientIP
+	SessionAffinity       corev1.ServiceAffinity        `json:"sessionAffinity,omitempty"`
+	SessionAffinityConfig *corev1.SessionAffinityConfig `json:"sessionAffinityConfig,omitempty"`
+	// PodLabels are added to the k8sgpt pod only, the app selector label cannot be overwritten
+	PodLabels map[string]string `json:"podLabels,omitempty"`
+	// DNSPolicy of the k8sgpt pod, defaults to ClusterFirst
+	// +kubebuilder:default:=ClusterFirst
+	// +kubebuilder:validation:Enum=ClusterFirstWithHostNet;ClusterFirst;Default;None
+	DNSPolicy corev1.DNSPolicy `json:"dnsPolicy,omitempty"`
+	// DNSConfig of the k8sgpt pod, e.g. a resolver for private AI endpoints
+	DNSConfig *corev1.PodDNSConfig `json:"dnsConfig,omitempty"`
+	// EphemeralContainerPolicy controls whether k8sgpt may inject debug containers
+	EphemeralContainerPolicy *EphemeralContainerPolicySpec `json:"ephemeralContainerPolicy,omitempty"`
+	// ServicePort of the k8sgpt Service and the port of the k8sgpt container
+	ServicePort *ServicePortSpec `json:"servicePort,omitempty"`
+	// PodSecurityPolicyName the k8sgpt ServiceAccount may use. PodSecurityPolicies
+	// were removed in Kubernetes 1.25, the field is ignored on newer clusters.
+	PodSecurityPolicyName string `json:"podSecurityPolicyName,omitempty"`
+	// Observability configures the OpenTelemetry export of k8sgpt
+	Observability *ObservabilitySpec `json:"observability,omitempty"`
+	// GRPCMaxMessageSizeMB of the messages between the operator and k8sgpt,
+	// large clusters may exceed the default of 4MB with their results
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default:=4
+	// +kubebuilder:validation:Maximum=512
+	GRPCMaxMessageSizeMB int32 `json:"grpcMaxMessageSizeMB,omitempty"`
+	// RuntimeTuning of the k8sgpt Go runtime
+	RuntimeTuning *RuntimeTuningSpec `json:"runtimeTuning,omitempty"`
+	// ScheduledAnalysis runs the analysis with a CronJob instead of the
+	// continuously serving k8sgpt deployment
+	ScheduledAnalysis *ScheduledAnalysisSpec `json:"scheduledAnalysis,omitempty"`
+	// ResourceQuota creates a ResourceQuota limiting the total resources of the
+	// namespace k8sgpt is deployed to, the k8sgpt pod counts against it too
+	ResourceQuota *corev1.ResourceQuotaSpec `json:"resourceQuota,omitempty"`
+	// UpdatePolicy restricts when the operator applies changes of the spec,
+	// e.g. to a maintenance window. The k8sgpt deployment is created right away.
+	UpdatePolicy *UpdatePolicySpec `json:"updatePolicy,omitempty"`
+	// StartupProbe of the k8sgpt container, delays the other probes until
+	// k8sgpt has started on slow hardware. Defaults to /healthz with 30 failures.
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+	// LivenessProbe of the k8sgpt container
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+	// RemoteKubeconfig references a secret with a kubeconfig key, k8sgpt analyzes
+	// the cluster of the kubeconfig instead of the one it runs in. The secret
+	// must be in the namespace of the k8sgpt deployment.
+	RemoteKubeconfig *corev1.SecretReference `json:"remoteKubeconfig,omitempty"`
+	// Notifications about the K8sGPT becoming degraded
+	Notifications *NotificationSpec `json:"notifications,omitempty"`
+}
+
+type NotificationSpec struct {
+	Slack *SlackNotificationSpec `json:"slack,omitempty"`
+}
+
+type SlackNotificationSpec struct {
+	// WebhookURLSecretRef references the URL of a slack incoming webhook, the
+	// secret must be in the namespace of the k8sgpt deployment
+	WebhookURLSecretRef corev1.SecretKeySelector `json:"webhookURLSecretRef"`
+	// Channel overrides the default channel of the incoming webhook
+	Channel string `json:"channel,omitempty"`
 }
 
 const (
@@ -115,12 +468,53 @@ const (
 	AmazonBedrock   = "amazonbedrock"
 	AmazonSageMaker = "AmazonSageMaker"
 	Cohere          = "cohere"
+	Anthropic       = "anthropic"
+	Groq            = "groq"
+	Mistral         = "mistral"
+)
+
+// K8sGPTNameLabel and K8sGPTNamespaceLabel select the objects of a K8sGPT
+// instance, including its Results
+const (
+	K8sGPTNameLabel      = "k8sgpts.k8sgpt.ai/name"
+	K8sGPTNamespaceLabel = "k8sgpts.k8sgpt.ai/namespace"
 )
 
+// DefaultGRPCMaxMessageSizeMB is the default message size limit of gRPC
+const DefaultGRPCMaxMessageSizeMB = 4
+
+// FunctionCallingBackends are the backends supporting function calling
+var FunctionCallingBackends = []string{OpenAI, AzureOpenAI, Anthropic, Groq}
+
+// SupportedLanguages of the analysis
+var SupportedLanguages = []string{"english", "spanish", "french", "german", "italian", "portuguese",
+	"dutch", "russian", "chinese", "japanese", "korean"}
+
 // K8sGPTStatus defines the observed state of K8sGPT
 type K8sGPTStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// ChangeHistory of the spec, limited to the last MaxChangeHistory changes
+	ChangeHistory []ChangeRecord `json:"changeHistory,omitempty"`
+	// TargetNamespace the objects of k8sgpt were last synced to, they are
+	// removed from it when spec.targetNamespace changes
+	TargetNamespace string `json:"targetNamespace,omitempty"`
+}
+
+// MaxChangeHistory is the number of changes kept in the status
+const MaxChangeHistory = 20
+
+// ChangeRecord describes a single change of the K8sGPT spec
+type ChangeRecord struct {
+	Time metav1.Time `json:"time"`
+	// Generation of the K8sGPT object after the change
+	Generation int64 `json:"generation"`
+	// ChangedBy is the user that changed the spec, as seen by the admission
+	// webhook. Informational, empty without the webhook.
+	ChangedBy string `json:"changedBy,omitempty"`
+	// ChangeSummary lists the changed fields of the spec
+	ChangeSummary string `json:"changeSummary,omitempty"`
 }
 
 //+kubebuilder:object:root=true
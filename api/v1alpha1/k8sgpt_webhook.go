@@ -0,0 +1,438 @@
+/*
+Copyright 2023 K8sGPT Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// AllowBackendChangeAnnotation permits a single update of spec.ai.backend, the
+// operator removes it again once the update has been applied
+const AllowBackendChangeAnnotation = "k8sgpt.ai/allow-backend-change"
+
+// ChangedByAnnotation and ChangeSummaryAnnotation describe the last change of
+// the spec, the operator records them in status.changeHistory. Only the webhook
+// sets them, values set by users are replaced. They are informational, the
+// audit log of the API server is the record of who changed what.
+const (
+	ChangedByAnnotation     = "k8sgpt.ai/changed-by"
+	ChangeSummaryAnnotation = "k8sgpt.ai/change-summary"
+)
+
+// log is for logging in this package.
+var k8sgptlog = logf.Log.WithName("k8sgpt-resource")
+
+// ForceDeleteAnnotation permits the deletion of a K8sGPT whose Results still exist
+const ForceDeleteAnnotation = "k8sgpt.ai/force-delete"
+
+// K8sGPTWebhook defaults and validates K8sGPT objects on admission
+// +kubebuilder:object:generate=false
+type K8sGPTWebhook struct {
+	// Client lists the Results of a K8sGPT on deletion, defaults to the client of the manager
+	Client client.Reader
+}
+
+func (w *K8sGPTWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	if w.Client == nil {
+		w.Client = mgr.GetClient()
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&K8sGPT{}).
+		WithDefaulter(w).
+		WithValidator(w).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-core-k8sgpt-ai-v1alpha1-k8sgpt,mutating=true,failurePolicy=fail,sideEffects=None,groups=core.k8sgpt.ai,resources=k8sgpts,verbs=create;update,versions=v1alpha1,name=mk8sgpt.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomDefaulter = &K8sGPTWebhook{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type
+func (w *K8sGPTWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	k8sgpt, ok := obj.(*K8sGPT)
+	if !ok {
+		return fmt.Errorf("expected a K8sGPT but got a %T", obj)
+	}
+	k8sgptlog.Info("default", "name", k8sgpt.Name)
+
+	if k8sgpt.Spec.ShareProcessNamespace == nil {
+		shareProcessNamespace := false
+		k8sgpt.Spec.ShareProcessNamespace = &shareProcessNamespace
+	}
+
+	if k8sgpt.Spec.ContainerSecurityContext == nil {
+		k8sgpt.Spec.ContainerSecurityContext = DefaultContainerSecurityContext()
+	}
+
+	if k8sgpt.Spec.DNSPolicy == "" {
+		k8sgpt.Spec.DNSPolicy = corev1.DNSClusterFirst
+	}
+
+	if k8sgpt.Spec.GRPCMaxMessageSizeMB == 0 {
+		k8sgpt.Spec.GRPCMaxMessageSizeMB = DefaultGRPCMaxMessageSizeMB
+	}
+
+	old, err := oldK8sGPT(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the image used to be configured with the top-level fields
+	if k8sgpt.Spec.Image == nil {
+		k8sgpt.Spec.Image = &ImageSpec{
+			Repository: k8sgpt.Spec.Repository,
+			Version:    k8sgpt.Spec.Version,
+		}
+	} else if old != nil {
+		followDeprecatedImage(old, k8sgpt)
+	}
+
+	if k8sgpt.Spec.StartupProbe == nil {
+		k8sgpt.Spec.StartupProbe = DefaultStartupProbe()
+	}
+
+	return annotateChange(ctx, k8sgpt, old)
+}
+
+// oldK8sGPT returns the K8sGPT before the update, nil for other operations
+func oldK8sGPT(ctx context.Context) (*K8sGPT, error) {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil || req.Operation != admissionv1.Update {
+		return nil, nil
+	}
+	old := &K8sGPT{}
+	if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// followDeprecatedImage copies a change of the deprecated top-level fields to
+// the image, clients unaware of spec.image still upgrade k8sgpt with them
+func followDeprecatedImage(old, k8sgpt *K8sGPT) {
+	if old.Spec.Image == nil {
+		return
+	}
+	image := k8sgpt.Spec.Image
+	if k8sgpt.Spec.Repository != "" && k8sgpt.Spec.Repository != old.Spec.Repository &&
+		image.Repository == old.Spec.Image.Repository {
+		image.Repository = k8sgpt.Spec.Repository
+	}
+	if k8sgpt.Spec.Version != "" && k8sgpt.Spec.Version != old.Spec.Version &&
+		image.Version == old.Spec.Image.Version {
+		image.Version = k8sgpt.Spec.Version
+	}
+}
+
+// annotateChange records who changed the spec and which fields. Updates that
+// leave the spec alone, e.g. of the finalizer, keep the previous change, values
+// of the annotations set by the user are not kept.
+func annotateChange(ctx context.Context, k8sgpt, old *K8sGPT) error {
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		// not called for an admission request
+		return nil
+	}
+
+	if k8sgpt.Annotations == nil {
+		k8sgpt.Annotations = map[string]string{}
+	}
+	summary := "created"
+	if old != nil {
+		changed, err := changedSpecFields(old.Spec, k8sgpt.Spec)
+		if err != nil {
+			return err
+		}
+		if len(changed) == 0 {
+			for _, annotation := range []string{ChangedByAnnotation, ChangeSummaryAnnotation} {
+				if value, ok := old.Annotations[annotation]; ok {
+					k8sgpt.Annotations[annotation] = value
+				} else {
+					delete(k8sgpt.Annotations, annotation)
+				}
+			}
+			return nil
+		}
+		summary = "changed " + strings.Join(changed, ", ")
+	}
+
+	k8sgpt.Annotations[ChangedByAnnotation] = req.UserInfo.Username
+	k8sgpt.Annotations[ChangeSummaryAnnotation] = summary
+	return nil
+}
+
+// changedSpecFields returns the sorted top level fields of the spec that differ
+func changedSpecFields(old, spec K8sGPTSpec) ([]string, error) {
+	oldFields, err := specFields(old)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := specFields(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	for name, value := range fields {
+		if !reflect.DeepEqual(oldFields[name], value) {
+			changed = append(changed, "spec."+name)
+		}
+	}
+	for name := range oldFields {
+		if _, ok := fields[name]; !ok {
+			changed = append(changed, "spec."+name)
+		}
+	}
+	sort.Strings(changed)
+	return changed, nil
+}
+
+func specFields(spec K8sGPTSpec) (map[string]interface{}, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, err
+	}
+	fields := map[string]interface{}{}
+	return fields, json.Unmarshal(data, &fields)
+}
+
+// DefaultContainerSecurityContext runs k8sgpt as non-root, complying with the
+// restricted Pod Security Standard
+func DefaultContainerSecurityContext() *corev1.SecurityContext {
+	runAsNonRoot := true
+	runAsUser := int64(65532)
+	readOnlyRootFilesystem := true
+	allowPrivilegeEscalation := false
+	return &corev1.SecurityContext{
+		RunAsNonRoot:             &runAsNonRoot,
+		RunAsUser:                &runAsUser,
+		ReadOnlyRootFilesystem:   &readOnlyRootFilesystem,
+		AllowPrivilegeEscalation: &allowPrivilegeEscalation,
+		Capabilities: &corev1.Capabilities{
+			Drop: []corev1.Capability{"ALL"},
+		},
+		SeccompProfile: &corev1.SeccompProfile{
+			Type: corev1.SeccompProfileTypeRuntimeDefault,
+		},
+	}
+}
+
+// DefaultStartupProbe gives k8sgpt up to 5 minutes to start, k8sgpt serves
+// /healthz next to the metrics
+func DefaultStartupProbe() *corev1.Probe {
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: "/healthz",
+				Port: intstr.FromInt(8081),
+			},
+		},
+		PeriodSeconds:    10,
+		FailureThreshold: 30,
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-core-k8sgpt-ai-v1alpha1-k8sgpt,mutating=false,failurePolicy=fail,sideEffects=None,groups=core.k8sgpt.ai,resources=k8sgpts,verbs=create;update;delete,versions=v1alpha1,name=vk8sgpt.kb.io,admissionReviewVersions=v1
+
+var _ webhook.CustomValidator = &K8sGPTWebhook{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type
+func (w *K8sGPTWebhook) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	k8sgpt, ok := obj.(*K8sGPT)
+	if !ok {
+		return nil, fmt.Errorf("expected a K8sGPT but got a %T", obj)
+	}
+	k8sgptlog.Info("validate create", "name", k8sgpt.Name)
+
+	if err := validateAI(k8sgpt.Spec.AI); err != nil {
+		return nil, err
+	}
+	warnings, err := validateOutputFormat(k8sgpt)
+	warnings = append(warnings, seedWarnings(k8sgpt)...)
+	return append(warnings, imageWarnings(k8sgpt)...), err
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type
+func (w *K8sGPTWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	k8sgpt, ok := newObj.(*K8sGPT)
+	if !ok {
+		return nil, fmt.Errorf("expected a K8sGPT but got a %T", newObj)
+	}
+	k8sgptlog.Info("validate update", "name", k8sgpt.Name)
+
+	old, ok := oldObj.(*K8sGPT)
+	if !ok {
+		return nil, fmt.Errorf("expected a K8sGPT but got a %T", oldObj)
+	}
+	if err := validateBackendChange(old, k8sgpt); err != nil {
+		return nil, err
+	}
+
+	if err := validateAI(k8sgpt.Spec.AI); err != nil {
+		return nil, err
+	}
+	warnings, err := validateOutputFormat(k8sgpt)
+	warnings = append(warnings, seedWarnings(k8sgpt)...)
+	return append(warnings, imageWarnings(k8sgpt)...), err
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type.
+// The Results of a K8sGPT are not deleted with it, so the deletion is rejected
+// while they exist, unless it is forced with an annotation.
+func (w *K8sGPTWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	k8sgpt, ok := obj.(*K8sGPT)
+	if !ok {
+		return nil, fmt.Errorf("expected a K8sGPT but got a %T", obj)
+	}
+	k8sgptlog.Info("validate delete", "name", k8sgpt.Name)
+
+	if k8sgpt.Annotations[ForceDeleteAnnotation] == "true" || w.Client == nil {
+		return nil, nil
+	}
+	// the Results are deleted along with a terminating namespace, blocking
+	// the K8sGPT would keep the namespace from being deleted
+	namespace := &corev1.Namespace{}
+	err := w.Client.Get(ctx, client.ObjectKey{Name: k8sgpt.Namespace}, namespace)
+	if client.IgnoreNotFound(err) != nil {
+		return nil, err
+	}
+	if err == nil && !namespace.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	results := &ResultList{}
+	err = w.Client.List(ctx, results, client.MatchingLabels{
+		K8sGPTNameLabel:      k8sgpt.Name,
+		K8sGPTNamespaceLabel: k8sgpt.Namespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(results.Items) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(results.Items))
+	for _, result := range results.Items {
+		names = append(names, result.Name)
+	}
+	sort.Strings(names)
+	return nil, apierrors.NewConflict(GroupVersion.WithResource("k8sgpts").GroupResource(), k8sgpt.Name,
+		fmt.Errorf("its results would be orphaned: %s. Delete them first or set the %s: \"true\" annotation",
+			strings.Join(names, ", "), ForceDeleteAnnotation))
+}
+
+func validateAI(ai *AISpec) error {
+	if ai == nil {
+		return nil
+	}
+	// Anthropic has no default model, one must always be chosen
+	if ai.Backend == Anthropic && ai.Model == "" {
+		return errors.New("spec.ai.model is required for the anthropic backend")
+	}
+	if ai.Backend == Groq && ai.Secret == nil {
+		return errors.New("spec.ai.secret is required for the groq backend")
+	}
+	if ai.Backend == Mistral && ai.Secret == nil {
+		return errors.New("spec.ai.secret is required for the mistral backend")
+	}
+	// Only the OpenAI reasoning models accept a reasoning effort
+	if ai.ReasoningEffort != "" && ai.Backend != OpenAI && ai.Backend != AzureOpenAI {
+		return fmt.Errorf("spec.ai.reasoningEffort is not supported by the %s backend", ai.Backend)
+	}
+	if ai.FunctionCalling && !supportsFunctionCalling(ai.Backend) {
+		return fmt.Errorf("spec.ai.functionCalling is not supported by the %s backend, supported backends are %s",
+			ai.Backend, strings.Join(FunctionCallingBackends, ", "))
+	}
+	return nil
+}
+
+// The slack sink posts the details as an mrkdwn attachment, it cannot
+// render json and only a subset of markdown
+func validateOutputFormat(k8sgpt *K8sGPT) (admission.Warnings, error) {
+	if k8sgpt.Spec.AI == nil || k8sgpt.Spec.Sink == nil || k8sgpt.Spec.Sink.Type != "slack" {
+		return nil, nil
+	}
+	switch k8sgpt.Spec.AI.OutputFormat {
+	case "json":
+		return nil, errors.New("spec.ai.outputFormat json is not supported by the slack sink")
+	case "markdown":
+		return admission.Warnings{
+			"the slack sink renders mrkdwn, markdown headings and links of the analysis may not display as expected",
+		}, nil
+	}
+	return nil, nil
+}
+
+// A fixed seed is meant for reproducible tests, the operator cannot tell
+// test from production namespaces, so it always warns
+func seedWarnings(k8sgpt *K8sGPT) admission.Warnings {
+	if k8sgpt.Spec.AI == nil || k8sgpt.Spec.AI.SeedValue == nil {
+		return nil
+	}
+	return admission.Warnings{
+		"spec.ai.seedValue makes the analysis reproducible and is meant for testing, remove it in production",
+	}
+}
+
+// A deprecated version differing from spec.image.version after defaulting was
+// set along with the image, or the image was changed since, it has no effect
+func imageWarnings(k8sgpt *K8sGPT) admission.Warnings {
+	if k8sgpt.Spec.Image == nil || k8sgpt.Spec.Version == "" || k8sgpt.Spec.Version == k8sgpt.Spec.Image.Version {
+		return nil
+	}
+	return admission.Warnings{
+		"spec.version is deprecated and ignored in favor of spec.image.version",
+	}
+}
+
+func supportsFunctionCalling(backend string) bool {
+	for _, b := range FunctionCallingBackends {
+		if b == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// Switching the backend of a running instance requires different credentials
+// and models, it has to be explicitly allowed with an annotation
+func validateBackendChange(old, k8sgpt *K8sGPT) error {
+	if old.Spec.AI == nil || k8sgpt.Spec.AI == nil || old.Spec.AI.Backend == k8sgpt.Spec.AI.Backend {
+		return nil
+	}
+	if k8sgpt.Annotations[AllowBackendChangeAnnotation] == "true" {
+		return nil
+	}
+	return fmt.Errorf("spec.ai.backend cannot be changed from %s to %s, set the %s: \"true\" annotation to allow it",
+		old.Spec.AI.Backend, k8sgpt.Spec.AI.Backend, AllowBackendChangeAnnotation)
+}
@@ -0,0 +1,418 @@
+/*
+Copyright 2023 K8sGPT Contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("The test cases for the K8sGPT webhook", func() {
+	var (
+		ctx     context.Context
+		webhook = &K8sGPTWebhook{}
+
+		newK8sGPT = func(ai *AISpec) *K8sGPT {
+			return &K8sGPT{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "k8s-gpt",
+					Namespace: "k8sGPT",
+				},
+				Spec: K8sGPTSpec{
+					AI: ai,
+				},
+			}
+		}
+
+		newRequest = func(operation admissionv1.Operation, old *K8sGPT) admission.Request {
+			req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Operation: operation,
+				UserInfo:  authenticationv1.UserInfo{Username: "jane"},
+			}}
+			if old != nil {
+				raw, err := json.Marshal(old)
+				Expect(err).ShouldNot(HaveOccurred())
+				req.OldObject = runtime.RawExtension{Raw: raw}
+			}
+			return req
+		}
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+	})
+
+	Context("Defaulting the K8sGPT spec", func() {
+		It("Should not share the process namespace by default", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.ShareProcessNamespace).ShouldNot(BeNil())
+			Expect(*k8sGPT.Spec.ShareProcessNamespace).Should(BeFalse())
+		})
+
+		It("Should run the container as non-root by default", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.ContainerSecurityContext).Should(Equal(DefaultContainerSecurityContext()))
+		})
+
+		It("Should keep an explicit container security context", func() {
+			runAsUser := int64(1000)
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			k8sGPT.Spec.ContainerSecurityContext = &corev1.SecurityContext{RunAsUser: &runAsUser}
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.ContainerSecurityContext.RunAsNonRoot).Should(BeNil())
+		})
+	})
+
+	Context("Defaulting the DNS policy", func() {
+		It("Should use ClusterFirst by default", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.DNSPolicy).Should(Equal(corev1.DNSClusterFirst))
+		})
+
+		It("Should keep an explicit DNS policy", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			k8sGPT.Spec.DNSPolicy = corev1.DNSNone
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.DNSPolicy).Should(Equal(corev1.DNSNone))
+		})
+	})
+
+	Context("Defaulting the gRPC message size", func() {
+		It("Should use the gRPC default of 4MB", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.GRPCMaxMessageSizeMB).Should(Equal(int32(DefaultGRPCMaxMessageSizeMB)))
+		})
+
+		It("Should keep an explicit message size", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			k8sGPT.Spec.GRPCMaxMessageSizeMB = 64
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.GRPCMaxMessageSizeMB).Should(Equal(int32(64)))
+		})
+
+		It("Should default the startup probe to /healthz", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.StartupProbe).Should(Equal(DefaultStartupProbe()))
+			Expect(k8sGPT.Spec.StartupProbe.HTTPGet.Path).Should(Equal("/healthz"))
+			Expect(k8sGPT.Spec.StartupProbe.FailureThreshold).Should(Equal(int32(30)))
+		})
+	})
+
+	Context("Validating the AI backend", func() {
+		It("Should accept an anthropic backend with a model", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: Anthropic, Model: "claude-3-opus-20240229"})
+			_, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should reject an anthropic backend without a model", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: Anthropic})
+			_, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).Should(HaveOccurred())
+			_, err = webhook.ValidateUpdate(ctx, k8sGPT, k8sGPT)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("Validating the groq backend", func() {
+		It("Should accept a groq backend with a secret", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: Groq, Secret: &SecretRef{Name: "groq-secret", Key: "api-key"}})
+			_, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should reject a groq backend without a secret", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: Groq})
+			_, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("Validating the mistral backend", func() {
+		It("Should accept a mistral backend with a secret", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: Mistral, Secret: &SecretRef{Name: "mistral-secret", Key: "api-key"}})
+			_, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should reject a mistral backend without a secret", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: Mistral})
+			_, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("Validating the reasoning effort", func() {
+		It("Should accept a reasoning effort for the openai backend", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI, Model: "o1", ReasoningEffort: "high"})
+			_, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should reject a reasoning effort for the localai backend", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: LocalAI, ReasoningEffort: "high"})
+			_, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("Validating function calling", func() {
+		It("Should accept function calling for the openai backend", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI, FunctionCalling: true})
+			_, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should reject function calling for the localai backend", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: LocalAI, FunctionCalling: true})
+			_, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).Should(MatchError(ContainSubstring("openai, azureopenai, anthropic, groq")))
+		})
+	})
+
+	Context("Validating the output format", func() {
+		It("Should reject json for the slack sink", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI, OutputFormat: "json"})
+			k8sGPT.Spec.Sink = &WebhookRef{Type: "slack", Endpoint: "https://hooks.slack.com/services/x"}
+			_, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("Should warn about markdown for the slack sink", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI, OutputFormat: "markdown"})
+			k8sGPT.Spec.Sink = &WebhookRef{Type: "slack", Endpoint: "https://hooks.slack.com/services/x"}
+			warnings, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(warnings).Should(HaveLen(1))
+		})
+
+		It("Should accept json without a sink", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI, OutputFormat: "json"})
+			warnings, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(warnings).Should(BeEmpty())
+		})
+	})
+
+	Context("Validating the seed value", func() {
+		It("Should warn about a seed value", func() {
+			seed := int64(42)
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI, SeedValue: &seed})
+			warnings, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(warnings).Should(HaveLen(1))
+		})
+
+		It("Should not warn without a seed value", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			warnings, err := webhook.ValidateCreate(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(warnings).Should(BeEmpty())
+		})
+	})
+
+	Context("Migrating the image", func() {
+		It("Should move the top-level repository and version to the image", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			k8sGPT.Spec.Repository = "ghcr.io/k8sgpt-ai/k8sgpt"
+			k8sGPT.Spec.Version = "v0.3.8"
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.Image).Should(Equal(&ImageSpec{Repository: "ghcr.io/k8sgpt-ai/k8sgpt", Version: "v0.3.8"}))
+		})
+
+		It("Should keep an explicit image", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			k8sGPT.Spec.Version = "v0.3.8"
+			k8sGPT.Spec.Image = &ImageSpec{Repository: "registry.example.com/k8sgpt", Version: "v0.3.9"}
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.Image.Version).Should(Equal("v0.3.9"))
+		})
+
+		It("Should apply a change of the top-level version to the migrated image", func() {
+			old := newK8sGPT(&AISpec{Backend: OpenAI})
+			old.Spec.Repository = "ghcr.io/k8sgpt-ai/k8sgpt"
+			old.Spec.Version = "v0.3.8"
+			Expect(webhook.Default(ctx, old)).Should(Succeed())
+			k8sGPT := old.DeepCopy()
+			k8sGPT.Spec.Version = "v0.3.9"
+			ctx := admission.NewContextWithRequest(ctx, newRequest(admissionv1.Update, old))
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.Image).Should(Equal(&ImageSpec{Repository: "ghcr.io/k8sgpt-ai/k8sgpt", Version: "v0.3.9"}))
+
+			// a change of the image itself wins
+			old = k8sGPT.DeepCopy()
+			k8sGPT.Spec.Version = "v0.4.0"
+			k8sGPT.Spec.Image.Version = "v0.4.1"
+			ctx = admission.NewContextWithRequest(ctx, newRequest(admissionv1.Update, old))
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Spec.Image.Version).Should(Equal("v0.4.1"))
+		})
+
+		It("Should warn about an ignored top-level version", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			k8sGPT.Spec.Version = "v0.3.8"
+			k8sGPT.Spec.Image = &ImageSpec{Version: "v0.3.9"}
+			warnings, err := webhook.ValidateUpdate(ctx, k8sGPT.DeepCopy(), k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(warnings).Should(HaveLen(1))
+		})
+	})
+
+	Context("Deleting a K8sGPT with results", func() {
+		var result *Result
+
+		BeforeEach(func() {
+			result = &Result{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "defaultpodfoo",
+					Namespace: "k8sGPT",
+					Labels: map[string]string{
+						K8sGPTNameLabel:      "k8s-gpt",
+						K8sGPTNamespaceLabel: "k8sGPT",
+					},
+				},
+			}
+			Expect(fakeClient.Create(ctx, result)).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			Expect(fakeClient.Delete(ctx, result)).Should(Succeed())
+		})
+
+		It("Should reject the deletion with a conflict", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			_, err := (&K8sGPTWebhook{Client: fakeClient}).ValidateDelete(ctx, k8sGPT)
+			Expect(apierrors.IsConflict(err)).Should(BeTrue())
+			Expect(err).Should(MatchError(ContainSubstring("defaultpodfoo")))
+		})
+
+		It("Should accept a forced deletion", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			k8sGPT.Annotations = map[string]string{ForceDeleteAnnotation: "true"}
+			_, err := (&K8sGPTWebhook{Client: fakeClient}).ValidateDelete(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should accept the deletion in a terminating namespace", func() {
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "k8sGPT", Finalizers: []string{"kubernetes"}},
+			}
+			Expect(fakeClient.Create(ctx, namespace)).Should(Succeed())
+			Expect(fakeClient.Delete(ctx, namespace)).Should(Succeed())
+			DeferCleanup(func() {
+				Expect(fakeClient.Get(ctx, client.ObjectKeyFromObject(namespace), namespace)).Should(Succeed())
+				namespace.Finalizers = nil
+				Expect(fakeClient.Update(ctx, namespace)).Should(Succeed())
+			})
+
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			_, err := (&K8sGPTWebhook{Client: fakeClient}).ValidateDelete(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		It("Should accept the deletion of another K8sGPT", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			k8sGPT.Name = "other"
+			_, err := (&K8sGPTWebhook{Client: fakeClient}).ValidateDelete(ctx, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("Changing the AI backend", func() {
+		It("Should reject a backend change without the annotation", func() {
+			old := newK8sGPT(&AISpec{Backend: OpenAI})
+			k8sGPT := newK8sGPT(&AISpec{Backend: LocalAI})
+			_, err := webhook.ValidateUpdate(ctx, old, k8sGPT)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("Should accept a backend change with the annotation", func() {
+			old := newK8sGPT(&AISpec{Backend: OpenAI})
+			k8sGPT := newK8sGPT(&AISpec{Backend: LocalAI})
+			k8sGPT.Annotations = map[string]string{AllowBackendChangeAnnotation: "true"}
+			_, err := webhook.ValidateUpdate(ctx, old, k8sGPT)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("Annotating changes of the spec", func() {
+		It("Should record the creator", func() {
+			k8sGPT := newK8sGPT(&AISpec{Backend: OpenAI})
+			ctx := admission.NewContextWithRequest(ctx, newRequest(admissionv1.Create, nil))
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Annotations).Should(HaveKeyWithValue(ChangedByAnnotation, "jane"))
+			Expect(k8sGPT.Annotations).Should(HaveKeyWithValue(ChangeSummaryAnnotation, "created"))
+		})
+
+		It("Should record the changed fields", func() {
+			old := newK8sGPT(&AISpec{Backend: OpenAI})
+			Expect(webhook.Default(ctx, old)).Should(Succeed())
+			k8sGPT := old.DeepCopy()
+			k8sGPT.Spec.AI.Model = "gpt-4"
+			k8sGPT.Spec.Version = "v0.3.9"
+			ctx := admission.NewContextWithRequest(ctx, newRequest(admissionv1.Update, old))
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Annotations).Should(HaveKeyWithValue(ChangeSummaryAnnotation, "changed spec.ai, spec.image, spec.version"))
+		})
+
+		It("Should keep the previous change when the spec is unchanged", func() {
+			old := newK8sGPT(&AISpec{Backend: OpenAI})
+			Expect(webhook.Default(ctx, old)).Should(Succeed())
+			old.Annotations = map[string]string{ChangedByAnnotation: "john"}
+			k8sGPT := old.DeepCopy()
+			k8sGPT.Finalizers = []string{"k8sgpt.ai/finalizer"}
+			ctx := admission.NewContextWithRequest(ctx, newRequest(admissionv1.Update, old))
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Annotations).Should(HaveKeyWithValue(ChangedByAnnotation, "john"))
+		})
+
+		It("Should not keep the annotations set by the user", func() {
+			old := newK8sGPT(&AISpec{Backend: OpenAI})
+			Expect(webhook.Default(ctx, old)).Should(Succeed())
+			old.Annotations = map[string]string{ChangedByAnnotation: "john"}
+			k8sGPT := old.DeepCopy()
+			k8sGPT.Annotations[ChangedByAnnotation] = "admin"
+			k8sGPT.Annotations[ChangeSummaryAnnotation] = "nothing to see"
+			ctx := admission.NewContextWithRequest(ctx, newRequest(admissionv1.Update, old))
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Annotations).Should(HaveKeyWithValue(ChangedByAnnotation, "john"))
+			Expect(k8sGPT.Annotations).ShouldNot(HaveKey(ChangeSummaryAnnotation))
+
+			k8sGPT = newK8sGPT(&AISpec{Backend: OpenAI})
+			k8sGPT.Annotations = map[string]string{ChangedByAnnotation: "admin"}
+			ctx = admission.NewContextWithRequest(ctx, newRequest(admissionv1.Create, nil))
+			Expect(webhook.Default(ctx, k8sGPT)).Should(Succeed())
+			Expect(k8sGPT.Annotations).Should(HaveKeyWithValue(ChangedByAnnotation, "jane"))
+		})
+	})
+})
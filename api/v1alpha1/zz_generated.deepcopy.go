@@ -22,17 +22,102 @@ limitations under the License.
 package v1alpha1
 
 import (
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AIRetryPolicy) DeepCopyInto(out *AIRetryPolicy) {
+	*out = *in
+	if in.RetryDelay != nil {
+		in, out := &in.RetryDelay, &out.RetryDelay
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AIRetryPolicy.
+func (in *AIRetryPolicy) DeepCopy() *AIRetryPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(AIRetryPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AISpec) DeepCopyInto(out *AISpec) {
 	*out = *in
+	if in.BaseUrlSecretRef != nil {
+		in, out := &in.BaseUrlSecretRef, &out.BaseUrlSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AzureAD != nil {
+		in, out := &in.AzureAD, &out.AzureAD
+		*out = new(AzureADSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Secret != nil {
 		in, out := &in.Secret, &out.Secret
 		*out = new(SecretRef)
 		**out = **in
 	}
+	if in.ModelOverridePerAnalyzer != nil {
+		in, out := &in.ModelOverridePerAnalyzer, &out.ModelOverridePerAnalyzer
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.TopP != nil {
+		in, out := &in.TopP, &out.TopP
+		*out = new(string)
+		**out = **in
+	}
+	if in.SeedValue != nil {
+		in, out := &in.SeedValue, &out.SeedValue
+		*out = new(int64)
+		**out = **in
+	}
+	if in.BackendFallback != nil {
+		in, out := &in.BackendFallback, &out.BackendFallback
+		*out = make([]AIBackend, len(*in))
+		copy(*out, *in)
+	}
+	if in.RetryPolicy != nil {
+		in, out := &in.RetryPolicy, &out.RetryPolicy
+		*out = new(AIRetryPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ExtraHeaders != nil {
+		in, out := &in.ExtraHeaders, &out.ExtraHeaders
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ExtraHeadersSecretRef != nil {
+		in, out := &in.ExtraHeadersSecretRef, &out.ExtraHeadersSecretRef
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.Plugins != nil {
+		in, out := &in.Plugins, &out.Plugins
+		*out = make([]PluginSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ResponseTimeout != nil {
+		in, out := &in.ResponseTimeout, &out.ResponseTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AISpec.
@@ -45,6 +130,41 @@ func (in *AISpec) DeepCopy() *AISpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertingRulesSpec) DeepCopyInto(out *AlertingRulesSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertingRulesSpec.
+func (in *AlertingRulesSpec) DeepCopy() *AlertingRulesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertingRulesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureADSpec) DeepCopyInto(out *AzureADSpec) {
+	*out = *in
+	if in.ClientSecretRef != nil {
+		in, out := &in.ClientSecretRef, &out.ClientSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureADSpec.
+func (in *AzureADSpec) DeepCopy() *AzureADSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureADSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AzureBackend) DeepCopyInto(out *AzureBackend) {
 	*out = *in
@@ -75,6 +195,22 @@ func (in *Backstage) DeepCopy() *Backstage {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ChangeRecord) DeepCopyInto(out *ChangeRecord) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChangeRecord.
+func (in *ChangeRecord) DeepCopy() *ChangeRecord {
+	if in == nil {
+		return nil
+	}
+	out := new(ChangeRecord)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CredentialsRef) DeepCopyInto(out *CredentialsRef) {
 	*out = *in
@@ -90,6 +226,46 @@ func (in *CredentialsRef) DeepCopy() *CredentialsRef {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EgressPolicySpec) DeepCopyInto(out *EgressPolicySpec) {
+	*out = *in
+	if in.AllowedCIDRs != nil {
+		in, out := &in.AllowedCIDRs, &out.AllowedCIDRs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedPorts != nil {
+		in, out := &in.AllowedPorts, &out.AllowedPorts
+		*out = make([]int32, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EgressPolicySpec.
+func (in *EgressPolicySpec) DeepCopy() *EgressPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EgressPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EphemeralContainerPolicySpec) DeepCopyInto(out *EphemeralContainerPolicySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EphemeralContainerPolicySpec.
+func (in *EphemeralContainerPolicySpec) DeepCopy() *EphemeralContainerPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EphemeralContainerPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ExtraOptionsRef) DeepCopyInto(out *ExtraOptionsRef) {
 	*out = *in
@@ -145,6 +321,21 @@ func (in *GCSBackend) DeepCopy() *GCSBackend {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageSpec) DeepCopyInto(out *ImageSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageSpec.
+func (in *ImageSpec) DeepCopy() *ImageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Integrations) DeepCopyInto(out *Integrations) {
 	*out = *in
@@ -171,7 +362,7 @@ func (in *K8sGPT) DeepCopyInto(out *K8sGPT) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K8sGPT.
@@ -227,6 +418,11 @@ func (in *K8sGPTList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K8sGPTSpec) DeepCopyInto(out *K8sGPTSpec) {
 	*out = *in
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(ImageSpec)
+		**out = **in
+	}
 	if in.Filters != nil {
 		in, out := &in.Filters, &out.Filters
 		*out = make([]string, len(*in))
@@ -257,6 +453,177 @@ func (in *K8sGPTSpec) DeepCopyInto(out *K8sGPTSpec) {
 		*out = new(Integrations)
 		(*in).DeepCopyInto(*out)
 	}
+	in.UpdateStrategy.DeepCopyInto(&out.UpdateStrategy)
+	if in.MaxSurge != nil {
+		in, out := &in.MaxSurge, &out.MaxSurge
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.ProgressDeadlineSeconds != nil {
+		in, out := &in.ProgressDeadlineSeconds, &out.ProgressDeadlineSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Monitoring != nil {
+		in, out := &in.Monitoring, &out.Monitoring
+		*out = new(MonitoringSpec)
+		**out = **in
+	}
+	if in.ShareProcessNamespace != nil {
+		in, out := &in.ShareProcessNamespace, &out.ShareProcessNamespace
+		*out = new(bool)
+		**out = **in
+	}
+	if in.EnvFrom != nil {
+		in, out := &in.EnvFrom, &out.EnvFrom
+		*out = make([]v1.EnvFromSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RuntimeClassName != nil {
+		in, out := &in.RuntimeClassName, &out.RuntimeClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.ExtraClusterRoleRules != nil {
+		in, out := &in.ExtraClusterRoleRules, &out.ExtraClusterRoleRules
+		*out = make([]rbacv1.PolicyRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VerticalPodAutoscaler != nil {
+		in, out := &in.VerticalPodAutoscaler, &out.VerticalPodAutoscaler
+		*out = new(VPASpec)
+		**out = **in
+	}
+	if in.RevisionHistoryLimit != nil {
+		in, out := &in.RevisionHistoryLimit, &out.RevisionHistoryLimit
+		*out = new(int32)
+		**out = **in
+	}
+	if in.AutomountServiceAccountToken != nil {
+		in, out := &in.AutomountServiceAccountToken, &out.AutomountServiceAccountToken
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ReadinessGates != nil {
+		in, out := &in.ReadinessGates, &out.ReadinessGates
+		*out = make([]v1.PodReadinessGate, len(*in))
+		copy(*out, *in)
+	}
+	if in.AlertingRules != nil {
+		in, out := &in.AlertingRules, &out.AlertingRules
+		*out = new(AlertingRulesSpec)
+		**out = **in
+	}
+	if in.ContainerSecurityContext != nil {
+		in, out := &in.ContainerSecurityContext, &out.ContainerSecurityContext
+		*out = new(v1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EgressPolicy != nil {
+		in, out := &in.EgressPolicy, &out.EgressPolicy
+		*out = new(EgressPolicySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ServiceAccountAnnotations != nil {
+		in, out := &in.ServiceAccountAnnotations, &out.ServiceAccountAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.WatchedNamespaces != nil {
+		in, out := &in.WatchedNamespaces, &out.WatchedNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.HostAliases != nil {
+		in, out := &in.HostAliases, &out.HostAliases
+		*out = make([]v1.HostAlias, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SessionAffinityConfig != nil {
+		in, out := &in.SessionAffinityConfig, &out.SessionAffinityConfig
+		*out = new(v1.SessionAffinityConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PodLabels != nil {
+		in, out := &in.PodLabels, &out.PodLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(v1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EphemeralContainerPolicy != nil {
+		in, out := &in.EphemeralContainerPolicy, &out.EphemeralContainerPolicy
+		*out = new(EphemeralContainerPolicySpec)
+		**out = **in
+	}
+	if in.ServicePort != nil {
+		in, out := &in.ServicePort, &out.ServicePort
+		*out = new(ServicePortSpec)
+		**out = **in
+	}
+	if in.Observability != nil {
+		in, out := &in.Observability, &out.Observability
+		*out = new(ObservabilitySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RuntimeTuning != nil {
+		in, out := &in.RuntimeTuning, &out.RuntimeTuning
+		*out = new(RuntimeTuningSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ScheduledAnalysis != nil {
+		in, out := &in.ScheduledAnalysis, &out.ScheduledAnalysis
+		*out = new(ScheduledAnalysisSpec)
+		**out = **in
+	}
+	if in.ResourceQuota != nil {
+		in, out := &in.ResourceQuota, &out.ResourceQuota
+		*out = new(v1.ResourceQuotaSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UpdatePolicy != nil {
+		in, out := &in.UpdatePolicy, &out.UpdatePolicy
+		*out = new(UpdatePolicySpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.StartupProbe != nil {
+		in, out := &in.StartupProbe, &out.StartupProbe
+		*out = new(v1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LivenessProbe != nil {
+		in, out := &in.LivenessProbe, &out.LivenessProbe
+		*out = new(v1.Probe)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RemoteKubeconfig != nil {
+		in, out := &in.RemoteKubeconfig, &out.RemoteKubeconfig
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+	if in.Notifications != nil {
+		in, out := &in.Notifications, &out.Notifications
+		*out = new(NotificationSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K8sGPTSpec.
@@ -272,6 +639,20 @@ func (in *K8sGPTSpec) DeepCopy() *K8sGPTSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *K8sGPTStatus) DeepCopyInto(out *K8sGPTStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ChangeHistory != nil {
+		in, out := &in.ChangeHistory, &out.ChangeHistory
+		*out = make([]ChangeRecord, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new K8sGPTStatus.
@@ -284,6 +665,110 @@ func (in *K8sGPTStatus) DeepCopy() *K8sGPTStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringSpec) DeepCopyInto(out *MonitoringSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringSpec.
+func (in *MonitoringSpec) DeepCopy() *MonitoringSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationSpec) DeepCopyInto(out *NotificationSpec) {
+	*out = *in
+	if in.Slack != nil {
+		in, out := &in.Slack, &out.Slack
+		*out = new(SlackNotificationSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationSpec.
+func (in *NotificationSpec) DeepCopy() *NotificationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObservabilitySpec) DeepCopyInto(out *ObservabilitySpec) {
+	*out = *in
+	if in.OTLPHeaders != nil {
+		in, out := &in.OTLPHeaders, &out.OTLPHeaders
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.OTLPHeadersSecretRef != nil {
+		in, out := &in.OTLPHeadersSecretRef, &out.OTLPHeadersSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObservabilitySpec.
+func (in *ObservabilitySpec) DeepCopy() *ObservabilitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ObservabilitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginSpec) DeepCopyInto(out *PluginSpec) {
+	*out = *in
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginSpec.
+func (in *PluginSpec) DeepCopy() *PluginSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedisBackend) DeepCopyInto(out *RedisBackend) {
+	*out = *in
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedisBackend.
+func (in *RedisBackend) DeepCopy() *RedisBackend {
+	if in == nil {
+		return nil
+	}
+	out := new(RedisBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *RemoteCacheRef) DeepCopyInto(out *RemoteCacheRef) {
 	*out = *in
@@ -307,6 +792,21 @@ func (in *RemoteCacheRef) DeepCopyInto(out *RemoteCacheRef) {
 		*out = new(AzureBackend)
 		**out = **in
 	}
+	if in.Redis != nil {
+		in, out := &in.Redis, &out.Redis
+		*out = new(RedisBackend)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EncryptionKey != nil {
+		in, out := &in.EncryptionKey, &out.EncryptionKey
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteCacheRef.
@@ -415,6 +915,26 @@ func (in *ResultStatus) DeepCopy() *ResultStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuntimeTuningSpec) DeepCopyInto(out *RuntimeTuningSpec) {
+	*out = *in
+	if in.GoGC != nil {
+		in, out := &in.GoGC, &out.GoGC
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuntimeTuningSpec.
+func (in *RuntimeTuningSpec) DeepCopy() *RuntimeTuningSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RuntimeTuningSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *S3Backend) DeepCopyInto(out *S3Backend) {
 	*out = *in
@@ -430,6 +950,21 @@ func (in *S3Backend) DeepCopy() *S3Backend {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ScheduledAnalysisSpec) DeepCopyInto(out *ScheduledAnalysisSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduledAnalysisSpec.
+func (in *ScheduledAnalysisSpec) DeepCopy() *ScheduledAnalysisSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ScheduledAnalysisSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecretRef) DeepCopyInto(out *SecretRef) {
 	*out = *in
@@ -460,6 +995,38 @@ func (in *Sensitive) DeepCopy() *Sensitive {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServicePortSpec) DeepCopyInto(out *ServicePortSpec) {
+	*out = *in
+	out.TargetPort = in.TargetPort
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServicePortSpec.
+func (in *ServicePortSpec) DeepCopy() *ServicePortSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServicePortSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SlackNotificationSpec) DeepCopyInto(out *SlackNotificationSpec) {
+	*out = *in
+	in.WebhookURLSecretRef.DeepCopyInto(&out.WebhookURLSecretRef)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SlackNotificationSpec.
+func (in *SlackNotificationSpec) DeepCopy() *SlackNotificationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SlackNotificationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Trivy) DeepCopyInto(out *Trivy) {
 	*out = *in
@@ -475,6 +1042,41 @@ func (in *Trivy) DeepCopy() *Trivy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpdatePolicySpec) DeepCopyInto(out *UpdatePolicySpec) {
+	*out = *in
+	if in.AllowedHours != nil {
+		in, out := &in.AllowedHours, &out.AllowedHours
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpdatePolicySpec.
+func (in *UpdatePolicySpec) DeepCopy() *UpdatePolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(UpdatePolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VPASpec) DeepCopyInto(out *VPASpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VPASpec.
+func (in *VPASpec) DeepCopy() *VPASpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VPASpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WebhookRef) DeepCopyInto(out *WebhookRef) {
 	*out = *in
@@ -16,8 +16,10 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	corev1alpha1 "github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
@@ -29,18 +31,42 @@ import (
 	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/utils"
 	"github.com/prometheus/client_golang/prometheus"
 	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
-	FinalizerName            = "k8sgpt.ai/finalizer"
-	ReconcileErrorInterval   = 10 * time.Second
-	ReconcileSuccessInterval = 30 * time.Second
+	FinalizerName                   = "k8sgpt.ai/finalizer"
+	SensitiveRulesCondition         = "SensitiveClusterRoleRules"
+	UnencryptedRemoteCacheCondition = "UnencryptedRemoteCache"
+	ShortRemoteCacheTTLCondition    = "ShortRemoteCacheTTL"
+	DegradedCondition               = "Degraded"
+	PodSecurityPolicyCondition      = "PodSecurityPolicyIgnored"
+	UpdateDeferredCondition         = "UpdateDeferred"
+	HostNetworkCondition            = "HostNetwork"
+	HostAliasesCondition            = "HostAliases"
+	UnsupportedLanguageCondition    = "UnsupportedLanguage"
+	PodSecurityViolationCondition   = "PodSecurityViolation"
+	ReconcileErrorInterval          = 10 * time.Second
+	ReconcileSuccessInterval        = 30 * time.Second
+	// DegradedNotificationDebounce is the minimum time between two
+	// notifications about the same K8sGPT becoming degraded
+	DegradedNotificationDebounce = 15 * time.Minute
 )
 
 var (
@@ -50,11 +76,11 @@ var (
 		Name: "k8sgpt_reconcile_error_count",
 		Help: "The total number of errors during reconcile",
 	})
-	// k8sgptNumberOfResults is a metric for the number of results
-	k8sgptNumberOfResults = prometheus.NewGauge(prometheus.GaugeOpts{
+	// k8sgptNumberOfResults is a metric for the number of results of each K8sGPT
+	k8sgptNumberOfResults = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "k8sgpt_number_of_results",
 		Help: "The total number of results",
-	})
+	}, []string{"name", "namespace"})
 	// k8sgptNumberOfResultsByType is a metric for the number of results by type
 	k8sgptNumberOfResultsByType = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 		Name: "k8sgpt_number_of_results_by_type",
@@ -79,6 +105,16 @@ type K8sGPTReconciler struct {
 	Integrations *integrations.Integrations
 	SinkClient   *sinks.Client
 	K8sGPTClient *kclient.Client
+	Recorder     record.EventRecorder
+	// ReconcileTimeout bounds a single reconcile, no timeout if zero
+	ReconcileTimeout time.Duration
+	// ServerVersion tells whether the cluster supports PodSecurityPolicies,
+	// they are not bound if nil. It is read once on start.
+	ServerVersion *version.Info
+
+	// degradedNotified is when the K8sGPTs were last notified as degraded
+	degradedNotified   map[types.NamespacedName]time.Time
+	degradedNotifiedMu sync.Mutex
 }
 
 // +kubebuilder:rbac:groups=core.k8sgpt.ai,resources=k8sgpts,verbs=get;list;watch;create;update;patch;delete
@@ -88,11 +124,34 @@ type K8sGPTReconciler struct {
 // +kubebuilder:rbac:groups="*",resources="*",verbs="*"
 // +kubebuilder:rbac:groups="apiextensions.k8s.io",resources="*",verbs="*"
 func (r *K8sGPTReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	// A reconcile stuck on the API server or the k8sgpt deployment must not block forever
+	if r.ReconcileTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.ReconcileTimeout)
+		defer cancel()
+	}
+	result, err := r.reconcile(ctx, req)
+	// the context may have expired already
+	r.updateDegraded(context.Background(), req, err)
+	// errors from gRPC or wrapped with %v do not tell about the deadline, the context does
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		k8sgptConfig := &corev1alpha1.K8sGPT{}
+		if r.Get(context.Background(), req.NamespacedName, k8sgptConfig) == nil {
+			r.Recorder.Eventf(k8sgptConfig, corev1.EventTypeWarning, "ReconcileTimeout",
+				"reconcile did not finish within %s", r.ReconcileTimeout)
+		}
+		return r.finishReconcile(err, false)
+	}
+
+	return result, err
+}
+
+func (r *K8sGPTReconciler) reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	_ = log.FromContext(ctx)
 
 	// Look up the instance for this reconcile request
 	k8sgptConfig := &corev1alpha1.K8sGPT{}
-	err := r.Get(ctx, req.NamespacedName, k8sgptConfig)
+	err = r.Get(ctx, req.NamespacedName, k8sgptConfig)
 	if err != nil {
 		// Error reading the object - requeue the request.
 		k8sgptReconcileErrorCount.Inc()
@@ -121,6 +180,14 @@ func (r *K8sGPTReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 				k8sgptReconcileErrorCount.Inc()
 				return r.finishReconcile(err, false)
 			}
+			if k8sgptConfig.Spec.PodSecurityPolicyName != "" {
+				err = resources.SyncPodSecurityPolicy(ctx, r.Client, *k8sgptConfig, resources.DestroyOp)
+				if err != nil {
+					k8sgptReconcileErrorCount.Inc()
+					return r.finishReconcile(err, false)
+				}
+			}
+			k8sgptNumberOfResults.DeleteLabelValues(k8sgptConfig.Name, k8sgptConfig.Namespace)
 			controllerutil.RemoveFinalizer(k8sgptConfig, FinalizerName)
 			if err := r.Update(ctx, k8sgptConfig); err != nil {
 				k8sgptReconcileErrorCount.Inc()
@@ -133,19 +200,87 @@ func (r *K8sGPTReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 	// Check and see if the instance is new or has a K8sGPT deployment in flight
 	deployment := v1.Deployment{}
-	err = r.Get(ctx, client.ObjectKey{Namespace: k8sgptConfig.Namespace,
+	err = r.Get(ctx, client.ObjectKey{Namespace: resources.GetTargetNamespace(*k8sgptConfig),
 		Name: "k8sgpt-deployment"}, &deployment)
 	if client.IgnoreNotFound(err) != nil {
 		k8sgptReconcileErrorCount.Inc()
 		return r.finishReconcile(err, false)
 	}
-	err = resources.Sync(ctx, r.Client, *k8sgptConfig, resources.SyncOp)
+	deploymentExists := err == nil
+
+	// Outside of the update windows, the existing deployment is left alone and
+	// keeps being queried, the changes are applied by a reconcile in the next window
+	updateWait, err := resources.NextUpdateWindow(k8sgptConfig.Spec.UpdatePolicy, time.Now())
 	if err != nil {
 		k8sgptReconcileErrorCount.Inc()
 		return r.finishReconcile(err, false)
 	}
+	deferUpdate := deploymentExists && updateWait > 0
+	pendingUpdate := false
+	if deferUpdate {
+		specHash, err := resources.GetSpecHash(*k8sgptConfig)
+		if err != nil {
+			k8sgptReconcileErrorCount.Inc()
+			return r.finishReconcile(err, false)
+		}
+		pendingUpdate = deployment.Spec.Template.Labels[resources.SpecHashLabel] != specHash
+		// the deferral is announced once, when it starts
+		if pendingUpdate && !meta.IsStatusConditionTrue(k8sgptConfig.Status.Conditions, UpdateDeferredCondition) {
+			r.Recorder.Eventf(k8sgptConfig, corev1.EventTypeNormal, "UpdateDeferred",
+				"changes are applied in the next update window in %s", updateWait.Round(time.Minute))
+		}
+	} else {
+		if deploymentExists {
+			if err := resources.ValidateBackendChange(&deployment, *k8sgptConfig); err != nil {
+				r.Recorder.Event(k8sgptConfig, corev1.EventTypeWarning, "BackendChangeRejected", err.Error())
+				k8sgptReconcileErrorCount.Inc()
+				return r.finishReconcile(err, false)
+			}
+		}
 
-	if deployment.Status.ReadyReplicas > 0 {
+		syncResults, err := resources.SyncWithResults(ctx, r.Client, *k8sgptConfig, resources.SyncOp)
+		if err != nil {
+			// name the objects that failed, the error of the first one is returned. The
+			// others were canceled by it.
+			for _, result := range syncResults {
+				if result.Error != nil && !errors.Is(result.Error, context.Canceled) {
+					r.Recorder.Eventf(k8sgptConfig, corev1.EventTypeWarning, "SyncFailed",
+						"failed to sync %s %s: %s", result.ObjectRef.Kind, result.ObjectRef.Name, result.Error)
+				}
+			}
+			k8sgptReconcileErrorCount.Inc()
+			return r.finishReconcile(err, false)
+		}
+
+		// The backend change has been applied, later changes must be allowed again
+		if _, ok := k8sgptConfig.Annotations[corev1alpha1.AllowBackendChangeAnnotation]; ok {
+			delete(k8sgptConfig.Annotations, corev1alpha1.AllowBackendChangeAnnotation)
+			if err := r.Update(ctx, k8sgptConfig); err != nil {
+				k8sgptReconcileErrorCount.Inc()
+				return r.finishReconcile(err, false)
+			}
+		}
+
+		if err := r.syncPodSecurityPolicy(ctx, k8sgptConfig); err != nil {
+			k8sgptReconcileErrorCount.Inc()
+			return r.finishReconcile(err, false)
+		}
+	}
+
+	// the changes are applied as soon as the window opens
+	if pendingUpdate {
+		defer func() {
+			result = requeueBefore(result, updateWait)
+		}()
+	}
+
+	if err := r.updateStatus(ctx, k8sgptConfig, pendingUpdate); err != nil {
+		k8sgptReconcileErrorCount.Inc()
+		return r.finishReconcile(err, false)
+	}
+
+	// a scheduled analysis has no k8sgpt server to query
+	if k8sgptConfig.Spec.ScheduledAnalysis == nil && deployment.Status.ReadyReplicas > 0 {
 
 		// Check the version of the deployment image matches the version set in the K8sGPT CR
 		imageURI := deployment.Spec.Template.Spec.Containers[0].Image
@@ -155,10 +290,11 @@ func (r *K8sGPTReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		imageVersion := image[1]
 
 		// if one of repository or tag is changed, we need to update the deployment
-		if imageRepository != k8sgptConfig.Spec.Repository || imageVersion != k8sgptConfig.Spec.Version {
+		configImage := resources.GetImage(*k8sgptConfig)
+		if !deferUpdate && (imageRepository != configImage.Repository || imageVersion != configImage.Version) {
 			// Update the deployment image
 			deployment.Spec.Template.Spec.Containers[0].Image = fmt.Sprintf("%s:%s",
-				imageRepository, k8sgptConfig.Spec.Version)
+				imageRepository, configImage.Version)
 			err = r.Update(ctx, &deployment)
 			if err != nil {
 				k8sgptReconcileErrorCount.Inc()
@@ -177,7 +313,8 @@ func (r *K8sGPTReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		// Log address
 		fmt.Printf("K8sGPT address: %s\n", address)
 
-		k8sgptClient, err := kclient.NewClient(address)
+		k8sgptClient, err := kclient.NewClient(address, kclient.DefaultDialTimeout,
+			kclient.MaxMessageSize(k8sgptConfig))
 		if err != nil {
 			k8sgptReconcileErrorCount.Inc()
 			return r.finishReconcile(err, false)
@@ -187,21 +324,21 @@ func (r *K8sGPTReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 		// Configure the k8sgpt deployment if required
 		if k8sgptConfig.Spec.RemoteCache != nil {
-			err = k8sgptClient.AddConfig(k8sgptConfig)
+			err = k8sgptClient.AddConfig(ctx, k8sgptConfig)
 			if err != nil {
 				k8sgptReconcileErrorCount.Inc()
 				return r.finishReconcile(err, false)
 			}
 		}
 		if k8sgptConfig.Spec.Integrations != nil {
-			err = k8sgptClient.AddIntegration(k8sgptConfig)
+			err = k8sgptClient.AddIntegration(ctx, k8sgptConfig)
 			if err != nil {
 				k8sgptReconcileErrorCount.Inc()
 				return r.finishReconcile(err, false)
 			}
 		}
 
-		response, err := k8sgptClient.ProcessAnalysis(deployment, k8sgptConfig)
+		response, err := k8sgptClient.ProcessAnalysis(ctx, deployment, k8sgptConfig)
 		if err != nil {
 			if k8sgptConfig.Spec.AI.Enabled {
 				k8sgptNumberOfFailedBackendAICalls.With(prometheus.Labels{
@@ -221,7 +358,8 @@ func (r *K8sGPTReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 
 		// Parse the k8sgpt-deployment response into a list of results
-		k8sgptNumberOfResults.Set(float64(len(response.Results)))
+		k8sgptNumberOfResults.WithLabelValues(k8sgptConfig.Name, k8sgptConfig.Namespace).
+			Set(float64(len(response.Results)))
 		rawResults, err := resources.MapResults(*r.Integrations, response.Results, *k8sgptConfig)
 		if err != nil {
 			k8sgptReconcileErrorCount.Inc()
@@ -324,6 +462,9 @@ func (r *K8sGPTReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 func (r *K8sGPTReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	c := ctrl.NewControllerManagedBy(mgr).
 		For(&corev1alpha1.K8sGPT{}).
+		// a rotated AI secret restarts the k8sgpt pods. Only the metadata of the
+		// secrets is cached, their data is read from the api server.
+		WatchesMetadata(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.findK8sGPTsForSecret)).
 		Complete(r)
 
 	metrics.Registry.MustRegister(k8sgptReconcileErrorCount,
@@ -334,6 +475,263 @@ func (r *K8sGPTReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return c
 }
 
+// findK8sGPTsForSecret returns the K8sGPT instances referencing the secret as their AI
+// secret or their extra headers secret
+func (r *K8sGPTReconciler) findK8sGPTsForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	k8sgptList := &corev1alpha1.K8sGPTList{}
+	if err := r.List(ctx, k8sgptList); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list K8sGPT instances for secret", "secret", secret.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, k8sgpt := range k8sgptList.Items {
+		if k8sgpt.Spec.AI == nil || resources.GetTargetNamespace(k8sgpt) != secret.GetNamespace() {
+			continue
+		}
+		// the keys of the extra headers secret are the headers
+		if (k8sgpt.Spec.AI.Secret != nil && k8sgpt.Spec.AI.Secret.Name == secret.GetName()) ||
+			(k8sgpt.Spec.AI.ExtraHeadersSecretRef != nil && k8sgpt.Spec.AI.ExtraHeadersSecretRef.Name == secret.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&k8sgpt)})
+		}
+	}
+	return requests
+}
+
+// getPodSecurityViolations returns the violations of the restricted Pod Security
+// Standard by the container security context, when the namespace of the k8sgpt
+// pod enforces it
+func (r *K8sGPTReconciler) getPodSecurityViolations(ctx context.Context, k8sgptConfig *corev1alpha1.K8sGPT) ([]string, error) {
+	namespace := &corev1.Namespace{}
+	err := r.Get(ctx, client.ObjectKey{Name: resources.GetTargetNamespace(*k8sgptConfig)}, namespace)
+	if err != nil {
+		return nil, client.IgnoreNotFound(err)
+	}
+	if namespace.Labels[resources.PodSecurityEnforceLabel] != resources.PodSecurityRestricted {
+		return nil, nil
+	}
+	return resources.GetRestrictedViolations(resources.GetContainerSecurityContext(*k8sgptConfig)), nil
+}
+
+// syncPodSecurityPolicy binds the k8sgpt ServiceAccount to the PodSecurityPolicy
+// of the spec, as long as the cluster still supports them. The binding is
+// removed once the policy is cleared.
+func (r *K8sGPTReconciler) syncPodSecurityPolicy(ctx context.Context, k8sgptConfig *corev1alpha1.K8sGPT) error {
+	if k8sgptConfig.Spec.PodSecurityPolicyName == "" || !resources.PodSecurityPolicySupported(r.ServerVersion) {
+		role := &rbacv1.Role{}
+		err := r.Get(ctx, client.ObjectKey{Name: resources.PodSecurityPolicyRoleName,
+			Namespace: resources.GetTargetNamespace(*k8sgptConfig)}, role)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return resources.SyncPodSecurityPolicy(ctx, r.Client, *k8sgptConfig, resources.DestroyOp)
+	}
+
+	return resources.SyncPodSecurityPolicy(ctx, r.Client, *k8sgptConfig, resources.SyncOp)
+}
+
+// updateStatus records changes of the spec and surfaces configurations that
+// are allowed, but risky
+func (r *K8sGPTReconciler) updateStatus(ctx context.Context, k8sgptConfig *corev1alpha1.K8sGPT, pendingUpdate bool) error {
+	status := k8sgptConfig.Status.DeepCopy()
+
+	recordChange(k8sgptConfig)
+
+	// the namespace the objects were synced to, they are removed from it once
+	// spec.targetNamespace changes
+	if !pendingUpdate {
+		k8sgptConfig.Status.TargetNamespace = resources.GetTargetNamespace(*k8sgptConfig)
+	}
+
+	if pendingUpdate {
+		meta.SetStatusCondition(&k8sgptConfig.Status.Conditions, metav1.Condition{
+			Type:               UpdateDeferredCondition,
+			Status:             metav1.ConditionTrue,
+			Reason:             "OutsideUpdateWindow",
+			Message:            "changes are applied in the next window of spec.updatePolicy",
+			ObservedGeneration: k8sgptConfig.Generation,
+		})
+	} else {
+		meta.RemoveStatusCondition(&k8sgptConfig.Status.Conditions, UpdateDeferredCondition)
+	}
+
+	// Extra rules granting all verbs on secrets or configmaps
+	sensitive := resources.GetSensitiveClusterRoleRules(*k8sgptConfig)
+	r.setWarningCondition(k8sgptConfig, SensitiveRulesCondition, "WildcardVerbs",
+		fmt.Sprintf("%d extra ClusterRole rules grant all verbs on secrets or configmaps", len(sensitive)),
+		len(sensitive) > 0)
+
+	// Results in a remote cache may contain sensitive cluster information
+	remoteCache := k8sgptConfig.Spec.RemoteCache
+	r.setWarningCondition(k8sgptConfig, UnencryptedRemoteCacheCondition, "NoEncryptionKey",
+		"the remote cache is not encrypted, set spec.remoteCache.encryptionKey",
+		remoteCache != nil && remoteCache.EncryptionKey == nil)
+
+	r.setWarningCondition(k8sgptConfig, PodSecurityPolicyCondition, "Unsupported",
+		"PodSecurityPolicies were removed in Kubernetes 1.25, spec.podSecurityPolicyName is ignored",
+		k8sgptConfig.Spec.PodSecurityPolicyName != "" && !resources.PodSecurityPolicySupported(r.ServerVersion))
+
+	// k8sgpt is queried on every reconcile, cached results expiring earlier are never reused
+	r.setWarningCondition(k8sgptConfig, ShortRemoteCacheTTLCondition, "TTLBelowAnalysisInterval",
+		fmt.Sprintf("the remote cache TTL is shorter than the analysis interval of %s, cached results expire before they are reused",
+			ReconcileSuccessInterval),
+		k8sgptConfig.Spec.ScheduledAnalysis == nil && remoteCache != nil && remoteCache.TTL != nil &&
+			remoteCache.TTL.Duration < ReconcileSuccessInterval)
+
+	// privileged configuration bypassing network policies
+	r.setWarningCondition(k8sgptConfig, HostNetworkCondition, "Enabled",
+		"k8sgpt runs in the host network, network policies do not apply to it",
+		k8sgptConfig.Spec.HostNetwork)
+
+	r.setWarningCondition(k8sgptConfig, HostAliasesCondition, "Configured",
+		"k8sgpt resolves hosts from spec.hostAliases, the entries bypass DNS and are not updated with it",
+		len(k8sgptConfig.Spec.HostAliases) > 0)
+
+	violations, err := r.getPodSecurityViolations(ctx, k8sgptConfig)
+	if err != nil {
+		return err
+	}
+	r.setWarningCondition(k8sgptConfig, PodSecurityViolationCondition, "RestrictedNamespace",
+		fmt.Sprintf("namespace %s enforces the restricted Pod Security Standard, the k8sgpt pod will be rejected: %s",
+			resources.GetTargetNamespace(*k8sgptConfig), strings.Join(violations, ", ")),
+		len(violations) > 0)
+
+	// k8sgpt passes the language on to the backend, which may ignore it
+	language := k8sgptConfig.Spec.AI.Language
+	r.setWarningCondition(k8sgptConfig, UnsupportedLanguageCondition, "UnknownLanguage",
+		fmt.Sprintf("language %s is not supported, the analysis may not be in it. Supported languages are %s",
+			language, strings.Join(corev1alpha1.SupportedLanguages, ", ")),
+		language != "" && !utils.ContainsString(corev1alpha1.SupportedLanguages, strings.ToLower(language)))
+
+	if equality.Semantic.DeepEqual(*status, k8sgptConfig.Status) {
+		return nil
+	}
+
+	return r.Status().Update(ctx, k8sgptConfig)
+}
+
+// updateDegraded sets the Degraded condition after a failed reconcile and
+// notifies about the transition, it is removed after the next successful one.
+// Conflicts are retried right away and do not degrade the K8sGPT.
+func (r *K8sGPTReconciler) updateDegraded(ctx context.Context, req ctrl.Request, reconcileErr error) {
+	if apierrors.IsConflict(reconcileErr) || errors.Is(reconcileErr, context.Canceled) {
+		return
+	}
+	k8sgptConfig := &corev1alpha1.K8sGPT{}
+	if r.Get(ctx, req.NamespacedName, k8sgptConfig) != nil {
+		return
+	}
+	degraded := meta.IsStatusConditionTrue(k8sgptConfig.Status.Conditions, DegradedCondition)
+	if (reconcileErr != nil) == degraded {
+		return
+	}
+	if reconcileErr == nil {
+		meta.RemoveStatusCondition(&k8sgptConfig.Status.Conditions, DegradedCondition)
+	} else {
+		meta.SetStatusCondition(&k8sgptConfig.Status.Conditions, metav1.Condition{
+			Type:               DegradedCondition,
+			Status:             metav1.ConditionTrue,
+			Reason:             "ReconcileFailed",
+			Message:            reconcileErr.Error(),
+			ObservedGeneration: k8sgptConfig.Generation,
+		})
+	}
+	if err := r.Status().Update(ctx, k8sgptConfig); err != nil {
+		fmt.Printf("Warning: failed to update the Degraded condition: %s\n", err)
+		return
+	}
+	if reconcileErr != nil {
+		r.notifyDegraded(ctx, k8sgptConfig, reconcileErr.Error())
+	}
+}
+
+// notifyDegraded posts the reason to slack, unless the K8sGPT was notified
+// about within DegradedNotificationDebounce, e.g. when it keeps flapping
+func (r *K8sGPTReconciler) notifyDegraded(ctx context.Context, k8sgptConfig *corev1alpha1.K8sGPT, reason string) {
+	notifications := k8sgptConfig.Spec.Notifications
+	if notifications == nil || notifications.Slack == nil || r.SinkClient == nil {
+		return
+	}
+	key := client.ObjectKeyFromObject(k8sgptConfig)
+	r.degradedNotifiedMu.Lock()
+	if last, ok := r.degradedNotified[key]; ok && time.Since(last) < DegradedNotificationDebounce {
+		r.degradedNotifiedMu.Unlock()
+		return
+	}
+	if r.degradedNotified == nil {
+		r.degradedNotified = map[types.NamespacedName]time.Time{}
+	}
+	r.degradedNotified[key] = time.Now()
+	r.degradedNotifiedMu.Unlock()
+
+	secretRef := notifications.Slack.WebhookURLSecretRef
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: secretRef.Name,
+		Namespace: resources.GetTargetNamespace(*k8sgptConfig)}, secret)
+	if err == nil {
+		err = sinks.NotifyDegraded(*r.SinkClient, string(secret.Data[secretRef.Key]),
+			notifications.Slack.Channel, k8sgptConfig.Name, reason)
+	}
+	if err != nil {
+		r.Recorder.Eventf(k8sgptConfig, corev1.EventTypeWarning, "NotificationFailed",
+			"failed to notify slack about the degraded K8sGPT: %s", err)
+	}
+}
+
+// recordChange appends the current generation to the change history, who
+// changed it and how is annotated by the webhook
+func recordChange(k8sgptConfig *corev1alpha1.K8sGPT) {
+	history := k8sgptConfig.Status.ChangeHistory
+	if len(history) > 0 && history[len(history)-1].Generation == k8sgptConfig.Generation {
+		return
+	}
+	summary := k8sgptConfig.Annotations[corev1alpha1.ChangeSummaryAnnotation]
+	if summary == "" {
+		summary = "changed spec"
+	}
+	history = append(history, corev1alpha1.ChangeRecord{
+		Time:          metav1.Now(),
+		Generation:    k8sgptConfig.Generation,
+		ChangedBy:     k8sgptConfig.Annotations[corev1alpha1.ChangedByAnnotation],
+		ChangeSummary: summary,
+	})
+	if len(history) > corev1alpha1.MaxChangeHistory {
+		history = history[len(history)-corev1alpha1.MaxChangeHistory:]
+	}
+	k8sgptConfig.Status.ChangeHistory = history
+}
+
+func (r *K8sGPTReconciler) setWarningCondition(k8sgptConfig *corev1alpha1.K8sGPT, conditionType, reason, message string, active bool) {
+	if !active {
+		meta.RemoveStatusCondition(&k8sgptConfig.Status.Conditions, conditionType)
+		return
+	}
+	// the warning event is emitted when the condition appears or its message changes
+	condition := meta.FindStatusCondition(k8sgptConfig.Status.Conditions, conditionType)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Message != message {
+		r.Recorder.Event(k8sgptConfig, corev1.EventTypeWarning, conditionType, message)
+	}
+	meta.SetStatusCondition(&k8sgptConfig.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: k8sgptConfig.Generation,
+	})
+}
+
+// requeueBefore makes sure the reconcile is requeued within wait
+func requeueBefore(result ctrl.Result, wait time.Duration) ctrl.Result {
+	if result.RequeueAfter == 0 || wait < result.RequeueAfter {
+		result.Requeue = true
+		result.RequeueAfter = wait
+	}
+	return result
+}
+
 func (r *K8sGPTReconciler) finishReconcile(err error, requeueImmediate bool) (ctrl.Result, error) {
 	if err != nil {
 		interval := ReconcileErrorInterval
@@ -0,0 +1,771 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	corev1alpha1 "github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/resources"
+	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/sinks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/version"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newTestReconciler(t *testing.T, objs ...runtime.Object) *K8sGPTReconciler {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, corev1alpha1.AddToScheme(scheme))
+
+	return &K8sGPTReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objs...).
+			WithStatusSubresource(&corev1alpha1.K8sGPT{}).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+}
+
+func Test_ReconcileShouldWarnAboutShortRemoteCacheTTL(t *testing.T) {
+	ctx := context.Background()
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "k8sgpt-operator-system",
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+			Version:    "v0.1.0",
+			AI: &corev1alpha1.AISpec{
+				Backend: corev1alpha1.OpenAI,
+				Model:   "gpt-3.5-turbo",
+			},
+			RemoteCache: &corev1alpha1.RemoteCacheRef{
+				Redis: &corev1alpha1.RedisBackend{Address: "redis:6379"},
+				TTL:   &metav1.Duration{Duration: 10 * time.Second},
+			},
+		},
+	}
+	r := newTestReconciler(t, k8sgpt)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	existing := &corev1alpha1.K8sGPT{}
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	condition := meta.FindStatusCondition(existing.Status.Conditions, ShortRemoteCacheTTLCondition)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+
+	existing.Spec.RemoteCache.TTL = &metav1.Duration{Duration: time.Hour}
+	require.NoError(t, r.Update(ctx, existing))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	assert.Nil(t, meta.FindStatusCondition(existing.Status.Conditions, ShortRemoteCacheTTLCondition))
+}
+
+func Test_ReconcileShouldWarnAboutUnsupportedLanguages(t *testing.T) {
+	ctx := context.Background()
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "k8sgpt-operator-system",
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+			Version:    "v0.1.0",
+			AI: &corev1alpha1.AISpec{
+				Backend:  corev1alpha1.OpenAI,
+				Model:    "gpt-3.5-turbo",
+				Language: "hindi",
+			},
+		},
+	}
+	r := newTestReconciler(t, k8sgpt)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+
+	// the deployment is still created
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, r.Get(ctx, types.NamespacedName{Name: resources.DeploymentName, Namespace: k8sgpt.Namespace}, deployment))
+
+	existing := &corev1alpha1.K8sGPT{}
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	assert.True(t, meta.IsStatusConditionTrue(existing.Status.Conditions, UnsupportedLanguageCondition))
+
+	// the languages are case insensitive
+	existing.Spec.AI.Language = "English"
+	require.NoError(t, r.Update(ctx, existing))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	assert.Nil(t, meta.FindStatusCondition(existing.Status.Conditions, UnsupportedLanguageCondition))
+}
+
+func Test_ReconcileShouldWarnAboutSensitiveClusterRoleRules(t *testing.T) {
+	ctx := context.Background()
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "k8sgpt-operator-system",
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+			Version:    "v0.1.0",
+			AI: &corev1alpha1.AISpec{
+				Backend: corev1alpha1.OpenAI,
+				Model:   "gpt-3.5-turbo",
+			},
+			ExtraClusterRoleRules: []rbacv1.PolicyRule{
+				{
+					APIGroups: []string{""},
+					Resources: []string{"secrets"},
+					Verbs:     []string{"*"},
+				},
+			},
+		},
+	}
+	r := newTestReconciler(t, k8sgpt)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	existing := &corev1alpha1.K8sGPT{}
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	condition := meta.FindStatusCondition(existing.Status.Conditions, SensitiveRulesCondition)
+	require.NotNil(t, condition)
+	assert.Equal(t, metav1.ConditionTrue, condition.Status)
+
+	// the rules are still granted
+	clusterRole := &rbacv1.ClusterRole{}
+	require.NoError(t, r.Get(ctx, types.NamespacedName{Name: "k8sgpt"}, clusterRole))
+	assert.Contains(t, clusterRole.Rules, k8sgpt.Spec.ExtraClusterRoleRules[0])
+
+	// the condition is removed together with the rules
+	existing.Spec.ExtraClusterRoleRules = nil
+	require.NoError(t, r.Update(ctx, existing))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	assert.Nil(t, meta.FindStatusCondition(existing.Status.Conditions, SensitiveRulesCondition))
+	require.NoError(t, r.Get(ctx, types.NamespacedName{Name: "k8sgpt"}, clusterRole))
+	assert.NotContains(t, clusterRole.Rules, k8sgpt.Spec.ExtraClusterRoleRules[0])
+}
+
+func Test_ReconcileShouldWarnAboutHostNetwork(t *testing.T) {
+	ctx := context.Background()
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "k8sgpt-operator-system",
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+			Version:    "v0.1.0",
+			AI: &corev1alpha1.AISpec{
+				Backend: corev1alpha1.OpenAI,
+				Model:   "gpt-3.5-turbo",
+			},
+			HostNetwork: true,
+		},
+	}
+	r := newTestReconciler(t, k8sgpt)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, r.Get(ctx, types.NamespacedName{Name: resources.DeploymentName, Namespace: k8sgpt.Namespace}, deployment))
+	assert.True(t, deployment.Spec.Template.Spec.HostNetwork)
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning HostNetwork")
+
+	existing := &corev1alpha1.K8sGPT{}
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	assert.True(t, meta.IsStatusConditionTrue(existing.Status.Conditions, HostNetworkCondition))
+
+	// the warning is not repeated
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+}
+
+func Test_ReconcileShouldWarnAboutHostAliases(t *testing.T) {
+	ctx := context.Background()
+	hostAliases := []corev1.HostAlias{
+		{IP: "10.0.0.10", Hostnames: []string{"llm.internal.example.com"}},
+	}
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "k8sgpt-operator-system",
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+			Version:    "v0.1.0",
+			AI: &corev1alpha1.AISpec{
+				Backend: corev1alpha1.LocalAI,
+				Model:   "ggml-gpt4all-j",
+				BaseUrl: "http://llm.internal.example.com:8080/v1",
+			},
+			HostAliases: hostAliases,
+		},
+	}
+	r := newTestReconciler(t, k8sgpt)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, r.Get(ctx, types.NamespacedName{Name: resources.DeploymentName, Namespace: k8sgpt.Namespace}, deployment))
+	assert.Equal(t, hostAliases, deployment.Spec.Template.Spec.HostAliases)
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning HostAliases")
+
+	existing := &corev1alpha1.K8sGPT{}
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	assert.True(t, meta.IsStatusConditionTrue(existing.Status.Conditions, HostAliasesCondition))
+
+	// the warning is not repeated
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+}
+
+func Test_ReconcileShouldBindPodSecurityPolicyOnOldClusters(t *testing.T) {
+	tests := []struct {
+		name      string
+		minor     string
+		wantBound bool
+	}{
+		{name: "1.24", minor: "24", wantBound: true},
+		{name: "1.25", minor: "25", wantBound: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			k8sgpt := &corev1alpha1.K8sGPT{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "k8sgpt-sample",
+					Namespace: "k8sgpt-operator-system",
+				},
+				Spec: corev1alpha1.K8sGPTSpec{
+					Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+					Version:    "v0.1.0",
+					AI: &corev1alpha1.AISpec{
+						Backend: corev1alpha1.LocalAI,
+						Model:   "ggml-gpt4all-j",
+					},
+					PodSecurityPolicyName: "restricted",
+				},
+			}
+			r := newTestReconciler(t, k8sgpt)
+			r.ServerVersion = &version.Info{Major: "1", Minor: tt.minor}
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+
+			_, err := r.Reconcile(ctx, req)
+			require.NoError(t, err)
+
+			roleBinding := &rbacv1.RoleBinding{}
+			err = r.Get(ctx, types.NamespacedName{Name: resources.PodSecurityPolicyRoleName, Namespace: k8sgpt.Namespace}, roleBinding)
+			recorder := r.Recorder.(*record.FakeRecorder)
+			if tt.wantBound {
+				require.NoError(t, err)
+				assert.Empty(t, recorder.Events)
+			} else {
+				assert.True(t, errors.IsNotFound(err))
+				require.Len(t, recorder.Events, 1)
+				assert.Contains(t, <-recorder.Events, "Warning PodSecurityPolicy")
+			}
+
+			// the warning is not repeated
+			_, err = r.Reconcile(ctx, req)
+			require.NoError(t, err)
+			assert.Empty(t, recorder.Events)
+
+			// the binding is removed with the policy
+			require.NoError(t, r.Get(ctx, req.NamespacedName, k8sgpt))
+			k8sgpt.Spec.PodSecurityPolicyName = ""
+			require.NoError(t, r.Update(ctx, k8sgpt))
+			_, err = r.Reconcile(ctx, req)
+			require.NoError(t, err)
+			err = r.Get(ctx, types.NamespacedName{Name: resources.PodSecurityPolicyRoleName, Namespace: k8sgpt.Namespace}, roleBinding)
+			assert.True(t, errors.IsNotFound(err))
+			err = r.Get(ctx, types.NamespacedName{Name: resources.PodSecurityPolicyRoleName, Namespace: k8sgpt.Namespace}, &rbacv1.Role{})
+			assert.True(t, errors.IsNotFound(err))
+		})
+	}
+}
+
+func Test_ReconcileShouldTimeOut(t *testing.T) {
+	ctx := context.Background()
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "k8sgpt-sample",
+			Namespace:  "k8sgpt-operator-system",
+			Finalizers: []string{FinalizerName},
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			AI: &corev1alpha1.AISpec{
+				Backend: corev1alpha1.OpenAI,
+			},
+		},
+	}
+	r := newTestReconciler(t, k8sgpt)
+	r.ReconcileTimeout = 10 * time.Millisecond
+	// behave like an API server that never answers for the deployment, the
+	// error does not wrap the deadline like the gRPC errors do not
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if _, ok := obj.(*appsv1.Deployment); ok {
+				<-ctx.Done()
+				return fmt.Errorf("rpc error: %v", ctx.Err())
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	})
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+
+	result, err := r.Reconcile(ctx, req)
+	assert.Error(t, err)
+	assert.True(t, result.Requeue)
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning ReconcileTimeout")
+}
+
+func Test_ReconcileShouldWarnAboutPodSecurityViolations(t *testing.T) {
+	ctx := context.Background()
+	namespace := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "k8sgpt-operator-system",
+			Labels: map[string]string{resources.PodSecurityEnforceLabel: resources.PodSecurityRestricted},
+		},
+	}
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "k8sgpt-operator-system",
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			AI: &corev1alpha1.AISpec{
+				Backend: corev1alpha1.OpenAI,
+			},
+			ContainerSecurityContext: &corev1.SecurityContext{},
+		},
+	}
+	r := newTestReconciler(t, namespace, k8sgpt)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+
+	// an empty security context runs the container as root
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning PodSecurityViolation")
+
+	existing := &corev1alpha1.K8sGPT{}
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	assert.True(t, meta.IsStatusConditionTrue(existing.Status.Conditions, PodSecurityViolationCondition))
+
+	// the warning is not repeated
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	existing.Spec.ContainerSecurityContext = nil
+	require.NoError(t, r.Update(ctx, existing))
+
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	assert.Nil(t, meta.FindStatusCondition(existing.Status.Conditions, PodSecurityViolationCondition))
+}
+
+func Test_ReconcileShouldRecordChanges(t *testing.T) {
+	ctx := context.Background()
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "k8sgpt-sample",
+			Namespace:  "k8sgpt-operator-system",
+			Generation: 1,
+			Annotations: map[string]string{
+				corev1alpha1.ChangedByAnnotation:     "jane",
+				corev1alpha1.ChangeSummaryAnnotation: "created",
+			},
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+			Version:    "v0.1.0",
+			AI: &corev1alpha1.AISpec{
+				Backend: corev1alpha1.OpenAI,
+				Model:   "gpt-3.5-turbo",
+			},
+		},
+	}
+	r := newTestReconciler(t, k8sgpt)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+
+	// the generation is recorded only once
+	for i := 0; i < 2; i++ {
+		_, err := r.Reconcile(ctx, req)
+		require.NoError(t, err)
+	}
+
+	existing := &corev1alpha1.K8sGPT{}
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	require.Len(t, existing.Status.ChangeHistory, 1)
+	record := existing.Status.ChangeHistory[0]
+	assert.Equal(t, int64(1), record.Generation)
+	assert.Equal(t, "jane", record.ChangedBy)
+	assert.Equal(t, "created", record.ChangeSummary)
+	assert.Equal(t, k8sgpt.Namespace, existing.Status.TargetNamespace)
+}
+
+func Test_RecordChangeShouldLimitTheHistory(t *testing.T) {
+	k8sgpt := &corev1alpha1.K8sGPT{}
+	for generation := int64(1); generation <= corev1alpha1.MaxChangeHistory+5; generation++ {
+		k8sgpt.Generation = generation
+		recordChange(k8sgpt)
+	}
+
+	history := k8sgpt.Status.ChangeHistory
+	require.Len(t, history, corev1alpha1.MaxChangeHistory)
+	assert.Equal(t, int64(6), history[0].Generation)
+	assert.Equal(t, int64(corev1alpha1.MaxChangeHistory+5), history[len(history)-1].Generation)
+	assert.Equal(t, "changed spec", history[0].ChangeSummary)
+}
+
+func Test_FindK8sGPTsForSecret(t *testing.T) {
+	ctx := context.Background()
+	newK8sGPT := func(name, secretName string) *corev1alpha1.K8sGPT {
+		k8sgpt := &corev1alpha1.K8sGPT{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "k8sgpt-operator-system"},
+			Spec: corev1alpha1.K8sGPTSpec{
+				AI: &corev1alpha1.AISpec{Backend: corev1alpha1.OpenAI},
+			},
+		}
+		if secretName != "" {
+			k8sgpt.Spec.AI.Secret = &corev1alpha1.SecretRef{Name: secretName, Key: "openai-api-key"}
+		}
+		return k8sgpt
+	}
+	r := newTestReconciler(t,
+		newK8sGPT("k8sgpt-sample", "k8sgpt-sample-secret"),
+		newK8sGPT("k8sgpt-other", "k8sgpt-other-secret"),
+		newK8sGPT("k8sgpt-local-ai", ""),
+	)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-sample-secret", Namespace: "k8sgpt-operator-system"},
+	}
+	requests := r.findK8sGPTsForSecret(ctx, secret)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "k8sgpt-sample", requests[0].Name)
+
+	// the extra headers secret is the other referenced one
+	withHeaders := newK8sGPT("k8sgpt-headers", "")
+	withHeaders.Spec.AI.ExtraHeadersSecretRef = &corev1.SecretReference{Name: "k8sgpt-sample-secret"}
+	r = newTestReconciler(t, withHeaders)
+	requests = r.findK8sGPTsForSecret(ctx, secret)
+	require.Len(t, requests, 1)
+	assert.Equal(t, "k8sgpt-headers", requests[0].Name)
+
+	// a secret of the same name in another namespace is not the referenced one
+	secret.Namespace = "default"
+	assert.Empty(t, r.findK8sGPTsForSecret(ctx, secret))
+}
+
+func Test_ReconcileShouldDeferChangesOutsideOfTheUpdateWindow(t *testing.T) {
+	ctx := context.Background()
+	// a window that opens in two hours
+	start := time.Now().UTC().Add(2 * time.Hour)
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "k8sgpt-operator-system",
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+			Version:    "v0.1.0",
+			AI: &corev1alpha1.AISpec{
+				Backend: corev1alpha1.OpenAI,
+				Model:   "gpt-3.5-turbo",
+			},
+			UpdatePolicy: &corev1alpha1.UpdatePolicySpec{
+				AllowedHours: []string{start.Format("15:04") + "-" + start.Add(time.Hour).Format("15:04")},
+			},
+		},
+	}
+	r := newTestReconciler(t, k8sgpt)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+	deploymentKey := types.NamespacedName{Name: resources.DeploymentName, Namespace: k8sgpt.Namespace}
+
+	// the deployment is created right away
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, r.Get(ctx, deploymentKey, deployment))
+
+	require.NoError(t, r.Get(ctx, req.NamespacedName, k8sgpt))
+	k8sgpt.Spec.Version = "v0.2.0"
+	require.NoError(t, r.Update(ctx, k8sgpt))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	require.NoError(t, r.Get(ctx, deploymentKey, deployment))
+	assert.Equal(t, "ghcr.io/k8sgpt-ai/k8sgpt:v0.1.0", deployment.Spec.Template.Spec.Containers[0].Image)
+	require.NoError(t, r.Get(ctx, req.NamespacedName, k8sgpt))
+	assert.True(t, meta.IsStatusConditionTrue(k8sgpt.Status.Conditions, UpdateDeferredCondition))
+	recorder := r.Recorder.(*record.FakeRecorder)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Normal UpdateDeferred")
+
+	// the deferral is announced only once
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Empty(t, recorder.Events)
+
+	// a window opening within the analysis interval requeues when it opens
+	start = time.Now().UTC().Add(10 * time.Second)
+	if start.Truncate(time.Minute).Before(time.Now().UTC()) {
+		start = start.Truncate(time.Minute).Add(time.Minute)
+	}
+	k8sgpt.Spec.UpdatePolicy.AllowedHours = []string{start.Format("15:04") + "-" + start.Add(time.Hour).Format("15:04")}
+	require.NoError(t, r.Update(ctx, k8sgpt))
+	wait := time.Until(start.Truncate(time.Minute))
+	result, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.True(t, result.Requeue)
+	assert.LessOrEqual(t, result.RequeueAfter, wait)
+}
+
+func Test_ReconcileShouldKeepTheBackendChangeAllowedUntilTheUpdateWindow(t *testing.T) {
+	ctx := context.Background()
+	// a window that opens in two hours
+	start := time.Now().UTC().Add(2 * time.Hour)
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "k8sgpt-operator-system",
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+			Version:    "v0.1.0",
+			AI: &corev1alpha1.AISpec{
+				Backend: corev1alpha1.OpenAI,
+				Model:   "gpt-3.5-turbo",
+			},
+			UpdatePolicy: &corev1alpha1.UpdatePolicySpec{
+				AllowedHours: []string{start.Format("15:04") + "-" + start.Add(time.Hour).Format("15:04")},
+			},
+		},
+	}
+	r := newTestReconciler(t, k8sgpt)
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+	deploymentKey := types.NamespacedName{Name: resources.DeploymentName, Namespace: k8sgpt.Namespace}
+	backend := func() string {
+		deployment := &appsv1.Deployment{}
+		require.NoError(t, r.Get(ctx, deploymentKey, deployment))
+		for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+			if env.Name == "K8SGPT_BACKEND" {
+				return env.Value
+			}
+		}
+		return ""
+	}
+
+	_, err := r.Reconcile(ctx, req)
+	require.NoError(t, err)
+
+	require.NoError(t, r.Get(ctx, req.NamespacedName, k8sgpt))
+	k8sgpt.Annotations = map[string]string{corev1alpha1.AllowBackendChangeAnnotation: "true"}
+	k8sgpt.Spec.AI.Backend = corev1alpha1.LocalAI
+	require.NoError(t, r.Update(ctx, k8sgpt))
+
+	// the change is deferred, so is the removal of the annotation
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, corev1alpha1.OpenAI, backend())
+	require.NoError(t, r.Get(ctx, req.NamespacedName, k8sgpt))
+	assert.Equal(t, "true", k8sgpt.Annotations[corev1alpha1.AllowBackendChangeAnnotation])
+
+	// the window opens
+	k8sgpt.Spec.UpdatePolicy = nil
+	require.NoError(t, r.Update(ctx, k8sgpt))
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, corev1alpha1.LocalAI, backend())
+	require.NoError(t, r.Get(ctx, req.NamespacedName, k8sgpt))
+	assert.NotContains(t, k8sgpt.Annotations, corev1alpha1.AllowBackendChangeAnnotation)
+}
+
+func Test_RequeueBefore(t *testing.T) {
+	result := requeueBefore(ctrl.Result{Requeue: true, RequeueAfter: ReconcileSuccessInterval}, time.Second)
+	assert.Equal(t, ctrl.Result{Requeue: true, RequeueAfter: time.Second}, result)
+
+	result = requeueBefore(ctrl.Result{Requeue: true, RequeueAfter: ReconcileSuccessInterval}, time.Hour)
+	assert.Equal(t, ctrl.Result{Requeue: true, RequeueAfter: ReconcileSuccessInterval}, result)
+}
+
+func Test_ReconcileShouldNameTheObjectThatFailedToSync(t *testing.T) {
+	ctx := context.Background()
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "k8sgpt-sample",
+			Namespace:  "k8sgpt-operator-system",
+			Finalizers: []string{FinalizerName},
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			AI: &corev1alpha1.AISpec{
+				Backend: corev1alpha1.OpenAI,
+			},
+		},
+	}
+	r := newTestReconciler(t, k8sgpt)
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*corev1.ServiceAccount); ok {
+				return errors.NewServiceUnavailable("quota exceeded")
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	})
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+
+	_, err := r.Reconcile(ctx, req)
+	require.Error(t, err)
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	require.Len(t, recorder.Events, 1)
+	assert.Contains(t, <-recorder.Events, "Warning SyncFailed failed to sync ServiceAccount k8sgpt: quota exceeded")
+}
+
+func Test_ReconcileShouldNotifySlackWhenDegraded(t *testing.T) {
+	ctx := context.Background()
+	var notifications atomic.Int32
+	var message sinks.SlackMessage
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		notifications.Add(1)
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&message))
+	}))
+	defer slack.Close()
+
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "k8sgpt-sample",
+			Namespace:  "k8sgpt-operator-system",
+			Finalizers: []string{FinalizerName},
+		},
+		Spec: corev1alpha1.K8sGPTSpec{
+			AI: &corev1alpha1.AISpec{
+				Backend: corev1alpha1.OpenAI,
+			},
+			Notifications: &corev1alpha1.NotificationSpec{
+				Slack: &corev1alpha1.SlackNotificationSpec{
+					WebhookURLSecretRef: corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "slack"},
+						Key:                  "webhook-url",
+					},
+					Channel: "#k8sgpt",
+				},
+			},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "slack", Namespace: "k8sgpt-operator-system"},
+		Data:       map[string][]byte{"webhook-url": []byte(slack.URL)},
+	}
+	r := newTestReconciler(t, k8sgpt, secret)
+	r.SinkClient = sinks.NewClient(time.Second)
+	failing := true
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*corev1.ServiceAccount); ok && failing {
+				return errors.NewServiceUnavailable("quota exceeded")
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	})
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+	existing := &corev1alpha1.K8sGPT{}
+
+	_, err := r.Reconcile(ctx, req)
+	require.Error(t, err)
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	assert.True(t, meta.IsStatusConditionTrue(existing.Status.Conditions, DegradedCondition))
+	assert.Equal(t, int32(1), notifications.Load())
+	assert.Equal(t, "#k8sgpt", message.Channel)
+	assert.Contains(t, message.Attachments[0].Text, "quota exceeded")
+
+	// still degraded, no transition
+	_, err = r.Reconcile(ctx, req)
+	require.Error(t, err)
+	assert.Equal(t, int32(1), notifications.Load())
+
+	failing = false
+	_, err = r.Reconcile(ctx, req)
+	require.NoError(t, err)
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	assert.Nil(t, meta.FindStatusCondition(existing.Status.Conditions, DegradedCondition))
+
+	// degraded again within the debounce
+	require.NoError(t, r.Delete(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt", Namespace: "k8sgpt-operator-system"},
+	}))
+	failing = true
+	_, err = r.Reconcile(ctx, req)
+	require.Error(t, err)
+	require.NoError(t, r.Get(ctx, req.NamespacedName, existing))
+	assert.True(t, meta.IsStatusConditionTrue(existing.Status.Conditions, DegradedCondition))
+	assert.Equal(t, int32(1), notifications.Load())
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1alpha1 "github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/resources"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("The K8sGPT lifecycle", Ordered, func() {
+	var (
+		ctx = context.Background()
+
+		namespace = &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-lifecycle"},
+		}
+		k8sgpt = &corev1alpha1.K8sGPT{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "k8sgpt-sample",
+				Namespace: namespace.Name,
+			},
+			Spec: corev1alpha1.K8sGPTSpec{
+				Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+				Version:    "v0.1.0",
+				AI: &corev1alpha1.AISpec{
+					Backend: corev1alpha1.OpenAI,
+					Model:   "gpt-3.5-turbo",
+				},
+			},
+		}
+
+		req           = ctrl.Request{NamespacedName: types.NamespacedName{Name: k8sgpt.Name, Namespace: k8sgpt.Namespace}}
+		deploymentKey = types.NamespacedName{Name: resources.DeploymentName, Namespace: k8sgpt.Namespace}
+		reconciler    *K8sGPTReconciler
+	)
+
+	BeforeEach(func() {
+		reconciler = &K8sGPTReconciler{
+			Client:   k8sClient,
+			Scheme:   scheme.Scheme,
+			Recorder: record.NewFakeRecorder(100),
+		}
+	})
+
+	It("should create the namespace and the K8sGPT", func() {
+		Expect(k8sClient.Create(ctx, namespace)).Should(Succeed())
+		Expect(k8sClient.Create(ctx, k8sgpt.DeepCopy())).Should(Succeed())
+	})
+
+	It("should create the deployment", func() {
+		_, err := reconciler.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, deploymentKey, deployment)).Should(Succeed())
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).Should(Equal("ghcr.io/k8sgpt-ai/k8sgpt:v0.1.0"))
+	})
+
+	It("should roll the new image out on a version change", func() {
+		existing := &corev1alpha1.K8sGPT{}
+		Expect(k8sClient.Get(ctx, req.NamespacedName, existing)).Should(Succeed())
+		existing.Spec.Image = &corev1alpha1.ImageSpec{Repository: "ghcr.io/k8sgpt-ai/k8sgpt", Version: "v0.2.0"}
+		Expect(k8sClient.Update(ctx, existing)).Should(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+
+		deployment := &appsv1.Deployment{}
+		Expect(k8sClient.Get(ctx, deploymentKey, deployment)).Should(Succeed())
+		Expect(deployment.Spec.Template.Spec.Containers[0].Image).Should(Equal("ghcr.io/k8sgpt-ai/k8sgpt:v0.2.0"))
+	})
+
+	It("should remove the deployment and the finalizer on delete", func() {
+		existing := &corev1alpha1.K8sGPT{}
+		Expect(k8sClient.Get(ctx, req.NamespacedName, existing)).Should(Succeed())
+		Expect(existing.Finalizers).Should(ContainElement(FinalizerName))
+		Expect(k8sClient.Delete(ctx, existing)).Should(Succeed())
+
+		_, err := reconciler.Reconcile(ctx, req)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(k8sClient.Get(ctx, deploymentKey, &appsv1.Deployment{})).ShouldNot(Succeed())
+		Expect(k8sClient.Get(ctx, req.NamespacedName, &corev1alpha1.K8sGPT{})).ShouldNot(Succeed())
+	})
+})
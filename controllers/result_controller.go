@@ -0,0 +1,108 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1alpha1 "github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// ResultReconciler removes Result objects whose parent K8sGPT no longer exists
+type ResultReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+}
+
+func (r *ResultReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	result := &corev1alpha1.Result{}
+	err := r.Get(ctx, req.NamespacedName, result)
+	if err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// Results are linked to the K8sGPT instance that produced them through labels
+	name, ok := result.Labels[corev1alpha1.K8sGPTNameLabel]
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+	namespace, ok := result.Labels[corev1alpha1.K8sGPTNamespaceLabel]
+	if !ok {
+		namespace = result.Namespace
+	}
+
+	k8sgptConfig := &corev1alpha1.K8sGPT{}
+	err = r.Get(ctx, client.ObjectKey{Name: name, Namespace: namespace}, k8sgptConfig)
+	if err == nil {
+		return ctrl.Result{}, nil
+	}
+	// Anything other than a missing owner is treated as transient and retried
+	if !errors.IsNotFound(err) {
+		return ctrl.Result{Requeue: true, RequeueAfter: ReconcileErrorInterval}, err
+	}
+
+	fmt.Printf("Warning: deleting orphaned result %s/%s, K8sGPT %s/%s no longer exists\n",
+		result.Namespace, result.Name, namespace, name)
+	if err := r.Delete(ctx, result); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ResultReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1alpha1.Result{}).
+		// the Results of a deleted K8sGPT are removed right away instead of
+		// with the next change or resync
+		Watches(&corev1alpha1.K8sGPT{}, handler.EnqueueRequestsFromMapFunc(r.findResultsForK8sGPT),
+			builder.WithPredicates(predicate.Funcs{
+				CreateFunc:  func(event.CreateEvent) bool { return false },
+				UpdateFunc:  func(event.UpdateEvent) bool { return false },
+				GenericFunc: func(event.GenericEvent) bool { return false },
+			})).
+		Complete(r)
+}
+
+// findResultsForK8sGPT returns the Results labeled with the K8sGPT instance
+func (r *ResultReconciler) findResultsForK8sGPT(ctx context.Context, k8sgpt client.Object) []reconcile.Request {
+	results := &corev1alpha1.ResultList{}
+	err := r.List(ctx, results, client.MatchingLabels{
+		corev1alpha1.K8sGPTNameLabel:      k8sgpt.GetName(),
+		corev1alpha1.K8sGPTNamespaceLabel: k8sgpt.GetNamespace(),
+	})
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to list the results of K8sGPT", "k8sgpt", k8sgpt.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(results.Items))
+	for _, result := range results.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&result)})
+	}
+	return requests
+}
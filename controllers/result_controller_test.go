@@ -0,0 +1,105 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	corev1alpha1 "github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_ResultReconcilerShouldDeleteOrphanedResults(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, corev1alpha1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	newResult := func(name, owner string) *corev1alpha1.Result {
+		return &corev1alpha1.Result{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels: map[string]string{
+					corev1alpha1.K8sGPTNameLabel:      owner,
+					corev1alpha1.K8sGPTNamespaceLabel: "default",
+				},
+			},
+		}
+	}
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-sample", Namespace: "default"},
+	}
+	owned := newResult("owned", "k8sgpt-sample")
+	orphaned := newResult("orphaned", "deleted-k8sgpt")
+
+	r := &ResultReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(k8sgpt, owned, orphaned).Build(),
+		Scheme: scheme,
+	}
+
+	for _, name := range []string{"owned", "orphaned"} {
+		_, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "default"}})
+		require.NoError(t, err)
+	}
+
+	err := r.Get(ctx, types.NamespacedName{Name: "owned", Namespace: "default"}, &corev1alpha1.Result{})
+	assert.NoError(t, err)
+	err = r.Get(ctx, types.NamespacedName{Name: "orphaned", Namespace: "default"}, &corev1alpha1.Result{})
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func Test_FindResultsForK8sGPT(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, corev1alpha1.AddToScheme(scheme))
+	ctx := context.Background()
+
+	newResult := func(name, owner, ownerNamespace string) *corev1alpha1.Result {
+		return &corev1alpha1.Result{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+				Labels: map[string]string{
+					corev1alpha1.K8sGPTNameLabel:      owner,
+					corev1alpha1.K8sGPTNamespaceLabel: ownerNamespace,
+				},
+			},
+		}
+	}
+	r := &ResultReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			newResult("owned", "k8sgpt-sample", "default"),
+			newResult("other", "k8sgpt-other", "default"),
+			newResult("other-namespace", "k8sgpt-sample", "k8sgpt-operator-system"),
+		).Build(),
+		Scheme: scheme,
+	}
+
+	k8sgpt := &corev1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-sample", Namespace: "default"},
+	}
+	requests := r.findResultsForK8sGPT(ctx, k8sgpt)
+	require.Len(t, requests, 1)
+	assert.Equal(t, types.NamespacedName{Name: "owned", Namespace: "default"}, requests[0].NamespacedName)
+}
@@ -27,12 +27,17 @@ import (
 
 	corev1alpha1 "github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
 	"github.com/k8sgpt-ai/k8sgpt-operator/controllers"
+	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/effectiveconfig"
 	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/integrations"
 	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/sinks"
+	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/summary"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/discovery"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	//+kubebuilder:scaffold:imports
@@ -54,8 +59,11 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var reconcileTimeout time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.DurationVar(&reconcileTimeout, "reconcile-timeout", 60*time.Second,
+		"The maximum duration of a single K8sGPT reconcile, 0 disables the timeout.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
@@ -82,6 +90,13 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "ea9c19f7.k8sgpt.ai",
+		// the secrets are read when needed, caching them would keep the
+		// secrets of the whole cluster in memory
+		Client: client.Options{
+			Cache: &client.CacheOptions{
+				DisableFor: []client.Object{&corev1.Secret{}},
+			},
+		},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -117,15 +132,51 @@ func main() {
 	}
 	sinkClient := sinks.NewClient(sinkTimeout)
 
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(mgr.GetConfig())
+	if err != nil {
+		setupLog.Error(err, "unable to create discovery client")
+		os.Exit(1)
+	}
+	serverVersion, err := discoveryClient.ServerVersion()
+	if err != nil {
+		setupLog.Error(err, "unable to read the server version")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.K8sGPTReconciler{
-		Client:       mgr.GetClient(),
-		Scheme:       mgr.GetScheme(),
-		Integrations: integration,
-		SinkClient:   sinkClient,
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		Integrations:     integration,
+		SinkClient:       sinkClient,
+		Recorder:         mgr.GetEventRecorderFor("k8sgpt-controller"),
+		ReconcileTimeout: reconcileTimeout,
+		ServerVersion:    serverVersion,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "K8sGPT")
 		os.Exit(1)
 	}
+	if err = (&controllers.ResultReconciler{
+		Client: mgr.GetClient(),
+		Scheme: mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Result")
+		os.Exit(1)
+	}
+	// Webhooks require serving certificates, see config/certmanager
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = (&corev1alpha1.K8sGPTWebhook{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "K8sGPT")
+			os.Exit(1)
+		}
+	}
+	if err = mgr.AddMetricsExtraHandler(summary.Path, &summary.Handler{Client: mgr.GetClient()}); err != nil {
+		setupLog.Error(err, "unable to add summary handler")
+		os.Exit(1)
+	}
+	if err = mgr.AddMetricsExtraHandler(effectiveconfig.Path, &effectiveconfig.Handler{Client: mgr.GetClient()}); err != nil {
+		setupLog.Error(err, "unable to add effective config handler")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
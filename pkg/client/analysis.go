@@ -12,7 +12,7 @@ import (
 	v1 "k8s.io/api/apps/v1"
 )
 
-func (c *Client) ProcessAnalysis(deployment v1.Deployment, config *v1alpha1.K8sGPT) (*common.K8sGPTReponse, error) {
+func (c *Client) ProcessAnalysis(ctx context.Context, deployment v1.Deployment, config *v1alpha1.K8sGPT) (*common.K8sGPTReponse, error) {
 
 	client := rpc.NewServerServiceClient(c.conn)
 	req := &schemav1.AnalyzeRequest{
@@ -24,9 +24,9 @@ func (c *Client) ProcessAnalysis(deployment v1.Deployment, config *v1alpha1.K8sG
 		Language:  config.Spec.AI.Language,
 	}
 
-	res, err := client.Analyze(context.Background(), req)
+	res, err := client.Analyze(ctx, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call Analyze RPC: %v", err)
+		return nil, fmt.Errorf("failed to call Analyze RPC: %w", err)
 	}
 
 	var target []v1alpha1.ResultSpec
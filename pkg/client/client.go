@@ -21,11 +21,31 @@ import (
 	"time"
 
 	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/resources"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials/insecure"
 	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+const DefaultDialTimeout = 10 * time.Second
+
+// retryPolicy retries the k8sgpt RPCs while the server is briefly unavailable,
+// e.g. during a rollout of the k8sgpt deployment
+const retryPolicy = `{
+	"methodConfig": [{
+		"name": [{"service": "schema.v1.ServerService"}],
+		"retryPolicy": {
+			"MaxAttempts": 4,
+			"InitialBackoff": "0.5s",
+			"MaxBackoff": "5s",
+			"BackoffMultiplier": 2.0,
+			"RetryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`
+
 // This is the client for communicating with the K8sGPT in cluster deployment
 type Client struct {
 	conn *grpc.ClientConn
@@ -35,11 +55,36 @@ func (c *Client) Close() error {
 	return c.conn.Close()
 }
 
-func NewClient(address string) (*Client, error) {
+// MaxMessageSize returns the message size limit in bytes the k8sgpt deployment
+// of the config is configured with
+func MaxMessageSize(k8sgptConfig *v1alpha1.K8sGPT) int {
+	sizeMB := k8sgptConfig.Spec.GRPCMaxMessageSizeMB
+	if sizeMB == 0 {
+		sizeMB = v1alpha1.DefaultGRPCMaxMessageSizeMB
+	}
+	return int(sizeMB) * 1024 * 1024
+}
+
+func NewClient(address string, dialTimeout time.Duration, maxMessageSize int) (*Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
 	// Connect to the K8sGPT server and create a new client
-	conn, err := grpc.Dial(address, grpc.WithInsecure())
+	conn, err := grpc.DialContext(ctx, address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithBlock(),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           backoff.DefaultConfig,
+			MinConnectTimeout: dialTimeout,
+		}),
+		grpc.WithDefaultServiceConfig(retryPolicy),
+		grpc.WithDefaultCallOptions(
+			grpc.MaxCallRecvMsgSize(maxMessageSize),
+			grpc.MaxCallSendMsgSize(maxMessageSize),
+		),
+	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create context: %v", err)
+		return nil, fmt.Errorf("failed to create context: %w", err)
 	}
 	client := &Client{conn: conn}
 
@@ -53,7 +98,7 @@ func GenerateAddress(ctx context.Context, cli client.Client, k8sgptConfig *v1alp
 	} else {
 		// Get service IP and port for k8sgpt-deployment
 		svc := &corev1.Service{}
-		err := cli.Get(ctx, client.ObjectKey{Namespace: k8sgptConfig.Namespace,
+		err := cli.Get(ctx, client.ObjectKey{Namespace: resources.GetTargetNamespace(*k8sgptConfig),
 			Name: "k8sgpt"}, svc)
 		if err != nil {
 			return "", nil
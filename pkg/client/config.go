@@ -9,7 +9,7 @@ import (
 	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
 )
 
-func (c *Client) AddConfig(config *v1alpha1.K8sGPT) error {
+func (c *Client) AddConfig(ctx context.Context, config *v1alpha1.K8sGPT) error {
 	client := rpc.NewServerServiceClient(c.conn)
 	req := &schemav1.AddConfigRequest{}
 	// If multiple caches are configured we pick S3
@@ -42,26 +42,29 @@ func (c *Client) AddConfig(config *v1alpha1.K8sGPT) error {
 				},
 			},
 		}
+	} else if config.Spec.RemoteCache.Redis != nil {
+		// Redis is configured through the environment of the k8sgpt deployment
+		return nil
 	}
 
-	_, err := client.AddConfig(context.Background(), req)
+	_, err := client.AddConfig(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to call AddConfig RPC: %v", err)
+		return fmt.Errorf("failed to call AddConfig RPC: %w", err)
 	}
 
 	return nil
 }
 
-func (c *Client) RemoveConfig(config *v1alpha1.K8sGPT) error {
+func (c *Client) RemoveConfig(ctx context.Context, config *v1alpha1.K8sGPT) error {
 	client := rpc.NewServerServiceClient(c.conn)
 
 	req := &schemav1.RemoveConfigRequest{
 		Cache: &schemav1.Cache{},
 	}
 
-	_, err := client.RemoveConfig(context.Background(), req)
+	_, err := client.RemoveConfig(ctx, req)
 	if err != nil {
-		return fmt.Errorf("failed to call RemoveConfig RPC: %v", err)
+		return fmt.Errorf("failed to call RemoveConfig RPC: %w", err)
 	}
 
 	return nil
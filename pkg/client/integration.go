@@ -9,13 +9,13 @@ import (
 	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
 )
 
-func (c *Client) AddIntegration(config *v1alpha1.K8sGPT) error {
+func (c *Client) AddIntegration(ctx context.Context, config *v1alpha1.K8sGPT) error {
 
 	// Check if the integration is active already
 	client := rpc.NewServerServiceClient(c.conn)
 	req := &schemav1.ListIntegrationsRequest{}
 
-	resp, err := client.ListIntegrations(context.Background(),
+	resp, err := client.ListIntegrations(ctx,
 		req)
 	if err != nil {
 		return err
@@ -37,9 +37,9 @@ func (c *Client) AddIntegration(config *v1alpha1.K8sGPT) error {
 			},
 		},
 	}
-	_, err = client.AddConfig(context.Background(), configUpdatereq)
+	_, err = client.AddConfig(ctx, configUpdatereq)
 	if err != nil {
-		return fmt.Errorf("failed to call AddConfig RPC: %v", err)
+		return fmt.Errorf("failed to call AddConfig RPC: %w", err)
 	}
 
 	return nil
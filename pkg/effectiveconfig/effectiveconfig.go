@@ -0,0 +1,148 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package effectiveconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/resources"
+	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/summary"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Path is registered as a subtree, requests are served for
+// Path + {namespace}/{name}/effective-config
+const Path = "/api/v1/k8sgpts/"
+
+const redacted = "REDACTED"
+
+// sensitiveEnvNames mark environment variables whose literal value is redacted
+var sensitiveEnvNames = []string{"PASSWORD", "SECRET", "TOKEN", "KEY"}
+
+// EffectiveConfig holds the objects a reconcile of the K8sGPT instance would apply now
+type EffectiveConfig struct {
+	Objects []client.Object `json:"objects"`
+}
+
+// Handler serves the effective config of a single K8sGPT instance.
+// Callers authenticate with a bearer token and need get on the k8sgpts.core.k8sgpt.ai object.
+type Handler struct {
+	Client client.Client
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, Path), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] != "effective-config" {
+		http.NotFound(w, r)
+		return
+	}
+	key := types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+
+	allowed, err := summary.Authorize(r.Context(), h.Client, r, &authorizationv1.ResourceAttributes{
+		Namespace: key.Namespace,
+		Name:      key.Name,
+		Group:     v1alpha1.GroupVersion.Group,
+		Resource:  "k8sgpts",
+		Verb:      "get",
+	})
+	if err != nil {
+		fmt.Printf("Error authorizing effective config request: %v\n", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !allowed {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	k8sgpt := &v1alpha1.K8sGPT{}
+	if err := h.Client.Get(r.Context(), key, k8sgpt); err != nil {
+		if errors.IsNotFound(err) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// the owner references need the type meta, which the client drops
+	k8sgpt.SetGroupVersionKind(v1alpha1.GroupVersion.WithKind("K8sGPT"))
+
+	// the spec itself is invalid, nothing would be applied
+	if errs := resources.ValidateConfig(*k8sgpt); len(errs) > 0 {
+		http.Error(w, utilerrors.NewAggregate(errs).Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	objs, err := resources.GetObjects(*k8sgpt)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	for _, obj := range objs {
+		if obj.GetObjectKind().GroupVersionKind().Empty() {
+			gvk, err := apiutil.GVKForObject(obj, h.Client.Scheme())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			obj.GetObjectKind().SetGroupVersionKind(gvk)
+		}
+		redact(obj)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(EffectiveConfig{Objects: objs}); err != nil {
+		fmt.Printf("Error writing effective config: %v\n", err)
+	}
+}
+
+// redact replaces literal values of sensitive environment variables, values
+// referenced from secrets are never resolved in the first place
+func redact(obj client.Object) {
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return
+	}
+	for i := range deployment.Spec.Template.Spec.Containers {
+		env := deployment.Spec.Template.Spec.Containers[i].Env
+		for j := range env {
+			if env[j].Value != "" && isSensitive(env[j].Name) {
+				env[j].Value = redacted
+			}
+		}
+	}
+}
+
+func isSensitive(name string) bool {
+	for _, sensitive := range sensitiveEnvNames {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+	return false
+}
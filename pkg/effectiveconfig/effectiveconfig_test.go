@@ -0,0 +1,129 @@
+package effectiveconfig
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func Test_EffectiveConfigHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	k8sgpt := &v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-sample", Namespace: "default"},
+		Spec: v1alpha1.K8sGPTSpec{
+			Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+			Version:    "v0.3.8",
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+				Model:   "gpt-3.5-turbo",
+				Secret: &v1alpha1.SecretRef{
+					Name: "k8sgpt-secret",
+					Key:  "openai-api-key",
+				},
+			},
+		},
+	}
+	// behave like the API server, only the admin token may get the K8sGPT object
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(k8sgpt).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			switch review := obj.(type) {
+			case *authenticationv1.TokenReview:
+				review.Status.Authenticated = review.Spec.Token != "invalid"
+				review.Status.User.Username = review.Spec.Token
+			case *authorizationv1.SubjectAccessReview:
+				attributes := review.Spec.ResourceAttributes
+				review.Status.Allowed = review.Spec.User == "admin" &&
+					attributes.Namespace == "default" && attributes.Name != ""
+			default:
+				return c.Create(ctx, obj, opts...)
+			}
+			return nil
+		},
+	}).Build()
+	handler := &Handler{Client: fakeClient}
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{name: "missing token", path: Path + "default/k8sgpt-sample/effective-config", wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", path: Path + "default/k8sgpt-sample/effective-config", token: "invalid", wantStatus: http.StatusUnauthorized},
+		{name: "not allowed", path: Path + "default/k8sgpt-sample/effective-config", token: "viewer", wantStatus: http.StatusForbidden},
+		{name: "unknown path", path: Path + "default/k8sgpt-sample", token: "admin", wantStatus: http.StatusNotFound},
+		{name: "unknown instance", path: Path + "default/missing/effective-config", token: "admin", wantStatus: http.StatusNotFound},
+		{name: "allowed", path: Path + "default/k8sgpt-sample/effective-config", token: "admin", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			config := struct {
+				Objects []unstructured.Unstructured `json:"objects"`
+			}{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &config))
+			kinds := []string{}
+			for _, obj := range config.Objects {
+				kinds = append(kinds, obj.GetKind())
+			}
+			assert.ElementsMatch(t, []string{"ServiceAccount", "ClusterRole", "ClusterRoleBinding", "Service", "Deployment"}, kinds)
+		})
+	}
+}
+
+func Test_Redact(t *testing.T) {
+	deployment := &appsv1.Deployment{
+		Spec: appsv1.DeploymentSpec{
+			Template: v1.PodTemplateSpec{
+				Spec: v1.PodSpec{
+					Containers: []v1.Container{
+						{
+							Env: []v1.EnvVar{
+								{Name: "K8SGPT_MODEL", Value: "gpt-3.5-turbo"},
+								{Name: "K8SGPT_PASSWORD", Value: "sk-1234"},
+								{Name: "K8SGPT_CACHE_ENCRYPTION_KEY", ValueFrom: &v1.EnvVarSource{}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	redact(deployment)
+
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	assert.Equal(t, "gpt-3.5-turbo", env[0].Value)
+	assert.Equal(t, redacted, env[1].Value)
+	assert.Empty(t, env[2].Value)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resources
+
+import (
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// The VerticalPodAutoscaler types are not vendored, its objects are handled as unstructured
+var VerticalPodAutoscalerGVK = schema.GroupVersionKind{
+	Group:   "autoscaling.k8s.io",
+	Version: "v1",
+	Kind:    "VerticalPodAutoscaler",
+}
+
+func vpaEnabled(config v1alpha1.K8sGPT) bool {
+	return config.Spec.VerticalPodAutoscaler != nil && config.Spec.VerticalPodAutoscaler.Enabled
+}
+
+// GetVerticalPodAutoscaler Create VerticalPodAutoscaler for the K8sGPT deployment
+func GetVerticalPodAutoscaler(config v1alpha1.K8sGPT) (*unstructured.Unstructured, error) {
+	updateMode := config.Spec.VerticalPodAutoscaler.UpdateMode
+	if updateMode == "" {
+		updateMode = "Auto"
+	}
+
+	vpa := &unstructured.Unstructured{}
+	vpa.SetGroupVersionKind(VerticalPodAutoscalerGVK)
+	vpa.SetName("k8sgpt")
+	vpa.SetNamespace(GetTargetNamespace(config))
+	vpa.SetOwnerReferences([]metav1.OwnerReference{ComputeOwnerReference(config)})
+	vpa.Object["spec"] = map[string]interface{}{
+		"targetRef": map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"name":       DeploymentName,
+		},
+		"updatePolicy": map[string]interface{}{
+			"updateMode": updateMode,
+		},
+	}
+
+	return vpa, nil
+}
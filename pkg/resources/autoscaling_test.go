@@ -0,0 +1,44 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func Test_GetVerticalPodAutoscaler(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			VerticalPodAutoscaler: &v1alpha1.VPASpec{
+				Enabled:    true,
+				UpdateMode: "Initial",
+			},
+		},
+	}
+
+	vpa, err := GetVerticalPodAutoscaler(config)
+	require.NoError(t, err)
+	assert.Equal(t, VerticalPodAutoscalerGVK, vpa.GroupVersionKind())
+	assert.Equal(t, "default", vpa.GetNamespace())
+	targetName, _, err := unstructured.NestedString(vpa.Object, "spec", "targetRef", "name")
+	require.NoError(t, err)
+	assert.Equal(t, DeploymentName, targetName)
+	updateMode, _, err := unstructured.NestedString(vpa.Object, "spec", "updatePolicy", "updateMode")
+	require.NoError(t, err)
+	assert.Equal(t, "Initial", updateMode)
+
+	objs, err := GetObjects(config)
+	require.NoError(t, err)
+	assert.Contains(t, objs, vpa)
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resources
+
+import (
+	"context"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const CronJobName = "k8sgpt-analysis"
+
+func scheduledAnalysisEnabled(config v1alpha1.K8sGPT) bool {
+	return config.Spec.ScheduledAnalysis != nil
+}
+
+// GetCronJob Create CronJob running the k8sgpt analysis on a schedule, instead
+// of the deployment serving it continuously. The pod is the one of the deployment.
+func GetCronJob(config v1alpha1.K8sGPT) (*batchv1.CronJob, error) {
+	deployment, er := GetDeployment(config)
+	if er != nil {
+		return &batchv1.CronJob{}, er
+	}
+
+	template := deployment.Spec.Template
+	template.Spec.RestartPolicy = corev1.RestartPolicyOnFailure
+	// the analysis is printed, nothing connects to the pod. Probes would kill
+	// the analysis, nothing is served.
+	template.Spec.Containers[0].Ports = nil
+	template.Spec.Containers[0].StartupProbe = nil
+	template.Spec.Containers[0].LivenessProbe = nil
+	template.Spec.Containers[0].ReadinessProbe = nil
+	template.Spec.Containers[0].Args = []string{
+		"analyze",
+		"--output=json",
+		"--backend=" + config.Spec.AI.Backend,
+	}
+	if config.Spec.AI.Enabled {
+		template.Spec.Containers[0].Args = append(template.Spec.Containers[0].Args, "--explain")
+	}
+
+	suspend := config.Spec.ScheduledAnalysis.Suspend
+	cronJob := batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            CronJobName,
+			Namespace:       GetTargetNamespace(config),
+			OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule: config.Spec.ScheduledAnalysis.Schedule,
+			Suspend:  &suspend,
+			// a slow analysis must not overlap with the next one
+			ConcurrencyPolicy: batchv1.ForbidConcurrent,
+			JobTemplate: batchv1.JobTemplateSpec{
+				Spec: batchv1.JobSpec{
+					Template: template,
+				},
+			},
+		},
+	}
+
+	return &cronJob, nil
+}
+
+// removeInactiveWorkload deletes the deployment when the analysis is scheduled
+// and the CronJob when it is served, after switching between both
+func removeInactiveWorkload(ctx context.Context, c client.Client, config v1alpha1.K8sGPT) error {
+	var obj client.Object = &batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{Name: CronJobName, Namespace: GetTargetNamespace(config)},
+	}
+	if scheduledAnalysisEnabled(config) {
+		obj = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: DeploymentName, Namespace: GetTargetNamespace(config)},
+		}
+	}
+	// looked up first, so that every sync does not send a delete
+	if er := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); er != nil {
+		if errors.IsNotFound(er) || meta.IsNoMatchError(er) {
+			return nil
+		}
+		return er
+	}
+	if er := c.Delete(ctx, obj); er != nil && !errors.IsNotFound(er) {
+		return er
+	}
+	return nil
+}
+
+// isWorkload reports whether the object runs k8sgpt and thus depends on the others
+func isWorkload(obj client.Object) bool {
+	switch obj.(type) {
+	case *appsv1.Deployment, *batchv1.CronJob:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_GetCronJob(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.Enabled = true
+		c.Spec.ScheduledAnalysis = &v1alpha1.ScheduledAnalysisSpec{Schedule: "0 * * * *", Suspend: true}
+		c.Spec.StartupProbe = v1alpha1.DefaultStartupProbe()
+		c.Spec.LivenessProbe = v1alpha1.DefaultStartupProbe()
+	})
+
+	cronJob, err := GetCronJob(config)
+	require.NoError(t, err)
+	assert.Equal(t, "0 * * * *", cronJob.Spec.Schedule)
+	assert.True(t, *cronJob.Spec.Suspend)
+	assert.Equal(t, batchv1.ForbidConcurrent, cronJob.Spec.ConcurrencyPolicy)
+	pod := cronJob.Spec.JobTemplate.Spec.Template.Spec
+	assert.Equal(t, v1.RestartPolicyOnFailure, pod.RestartPolicy)
+	assert.Equal(t, []string{"analyze", "--output=json", "--backend=openai", "--explain"}, pod.Containers[0].Args)
+	assert.Empty(t, pod.Containers[0].Ports)
+	assert.Nil(t, pod.Containers[0].StartupProbe)
+	assert.Nil(t, pod.Containers[0].LivenessProbe)
+	assert.Nil(t, pod.Containers[0].ReadinessProbe)
+	// the environment is the one of the deployment
+	assert.Contains(t, pod.Containers[0].Env, v1.EnvVar{Name: "K8SGPT_MODEL", Value: "gpt-3.5-turbo"})
+
+	config.Spec.ScheduledAnalysis.Schedule = " "
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_SyncShouldSwitchBetweenDeploymentAndCronJob(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.Background()
+	deploymentKey := client.ObjectKey{Name: DeploymentName, Namespace: "default"}
+	cronJobKey := client.ObjectKey{Name: CronJobName, Namespace: "default"}
+
+	config := newTestConfig(nil)
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	require.NoError(t, fakeClient.Get(ctx, deploymentKey, &appsv1.Deployment{}))
+
+	config.Spec.ScheduledAnalysis = &v1alpha1.ScheduledAnalysisSpec{Schedule: "@hourly"}
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	require.NoError(t, fakeClient.Get(ctx, cronJobKey, &batchv1.CronJob{}))
+	assert.True(t, errors.IsNotFound(fakeClient.Get(ctx, deploymentKey, &appsv1.Deployment{})))
+
+	config.Spec.ScheduledAnalysis = nil
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	require.NoError(t, fakeClient.Get(ctx, deploymentKey, &appsv1.Deployment{}))
+	assert.True(t, errors.IsNotFound(fakeClient.Get(ctx, cronJobKey, &batchv1.CronJob{})))
+}
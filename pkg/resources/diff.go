@@ -0,0 +1,164 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resources
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"gomodules.xyz/jsonpatch/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// ChangeType describes how an object would be modified by Sync
+type ChangeType string
+
+const (
+	CreateChange ChangeType = "Create"
+	UpdateChange ChangeType = "Update"
+	DeleteChange ChangeType = "Delete"
+	NoChange     ChangeType = "NoChange"
+)
+
+const FieldManager = "k8sgpt-operator"
+
+// ResourceChange is a human readable change to one of the managed objects
+type ResourceChange struct {
+	ObjectRef  corev1.ObjectReference `json:"objectRef"`
+	ChangeType ChangeType             `json:"changeType"`
+	// Patch is a JSON patch from the current to the desired object
+	Patch string `json:"patch,omitempty"`
+}
+
+// Diff returns the changes Sync would apply for the K8sGPT instance without
+// modifying the cluster, desired objects are applied with a server side dry-run
+func Diff(ctx context.Context, c client.Client, config v1alpha1.K8sGPT) ([]ResourceChange, error) {
+	if errs := ValidateConfig(config); len(errs) > 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+	objs, er := GetObjects(config)
+	if er != nil {
+		return nil, er
+	}
+
+	var changes []ResourceChange
+	for _, obj := range objs {
+		gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+		if err != nil {
+			return nil, err
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+		change := ResourceChange{
+			ObjectRef: corev1.ObjectReference{
+				APIVersion: gvk.GroupVersion().String(),
+				Kind:       gvk.Kind,
+				Namespace:  obj.GetNamespace(),
+				Name:       obj.GetName(),
+			},
+		}
+
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(gvk)
+		err = c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+		if err != nil && !errors.IsNotFound(err) {
+			return nil, err
+		}
+		exists := err == nil
+
+		switch {
+		// The instance is being deleted, everything it owns goes away
+		case !config.DeletionTimestamp.IsZero():
+			if !exists {
+				continue
+			}
+			change.ChangeType = DeleteChange
+		case !exists:
+			desired, err := json.Marshal(obj)
+			if err != nil {
+				return nil, err
+			}
+			change.ChangeType = CreateChange
+			change.Patch = string(desired)
+		default:
+			patch, err := dryRunApply(ctx, c, existing, obj)
+			if err != nil {
+				return nil, err
+			}
+			change.ChangeType = UpdateChange
+			change.Patch = patch
+			if patch == "" {
+				change.ChangeType = NoChange
+			}
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// dryRunApply applies obj with a server side dry-run and returns the JSON
+// patch between the existing and the resulting object, empty when nothing changes
+func dryRunApply(ctx context.Context, c client.Client, existing *unstructured.Unstructured, obj client.Object) (string, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return "", err
+	}
+	applied := &unstructured.Unstructured{Object: content}
+	// apply configurations must not carry these
+	unstructured.RemoveNestedField(applied.Object, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(applied.Object, "status")
+	err = c.Patch(ctx, applied, client.Apply, client.DryRunAll,
+		client.FieldOwner(FieldManager), client.ForceOwnership)
+	if err != nil {
+		return "", err
+	}
+
+	// server managed metadata would show up as noise in every diff
+	for _, u := range []*unstructured.Unstructured{existing, applied} {
+		u.SetManagedFields(nil)
+		u.SetResourceVersion("")
+		u.SetGeneration(0)
+		unstructured.RemoveNestedField(u.Object, "status")
+	}
+	before, err := json.Marshal(existing)
+	if err != nil {
+		return "", err
+	}
+	after, err := json.Marshal(applied)
+	if err != nil {
+		return "", err
+	}
+	ops, err := jsonpatch.CreatePatch(before, after)
+	if err != nil {
+		return "", err
+	}
+	if len(ops) == 0 {
+		return "", nil
+	}
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		return "", err
+	}
+
+	return string(patch), nil
+}
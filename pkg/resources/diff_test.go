@@ -0,0 +1,56 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_DiffShouldReportCreatesAndDeletes(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+		},
+	}
+
+	// nothing exists yet, every object is created
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	changes, err := Diff(ctx, fakeClient, config)
+	require.NoError(t, err)
+	require.Len(t, changes, 5)
+	for _, change := range changes {
+		assert.Equal(t, CreateChange, change.ChangeType)
+		assert.NotEmpty(t, change.Patch)
+	}
+	assert.Equal(t, "Deployment", changes[4].ObjectRef.Kind)
+	assert.Equal(t, DeploymentName, changes[4].ObjectRef.Name)
+
+	// an instance being deleted removes the objects that exist
+	svc := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt", Namespace: "default"}}
+	fakeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(svc).Build()
+	now := metav1.Now()
+	config.DeletionTimestamp = &now
+	changes, err = Diff(ctx, fakeClient, config)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, DeleteChange, changes[0].ChangeType)
+	assert.Equal(t, "Service", changes[0].ObjectRef.Kind)
+}
@@ -16,20 +16,39 @@ package resources
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	err "errors"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
 	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/utils"
+	"golang.org/x/sync/errgroup"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	nodev1 "k8s.io/api/node/v1"
 	r1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
@@ -40,25 +59,80 @@ const (
 	SyncOp SyncOrDestroy = iota
 	DestroyOp
 	DeploymentName = "k8sgpt-deployment"
+	SpecHashLabel  = "k8sgpt.ai/spec-hash"
+	// PromptTemplateResourceName is the placeholder every prompt template needs
+	PromptTemplateResourceName = "{{.ResourceName}}"
+	GroqBaseUrl                = "https://api.groq.com"
+	MistralBaseUrl             = "https://api.mistral.ai"
+	ExtraHeaderEnvPrefix       = "K8SGPT_EXTRA_HEADER_"
+	// SecretHashAnnotation records the hash of the AI secret the k8sgpt pods
+	// were started with, they are restarted when it changes
+	SecretHashAnnotation = "k8sgpt.ai/secret-hash"
+	// RemoteKubeconfigDir is where the secret of the remote kubeconfig is
+	// mounted, the kubeconfig is its RemoteKubeconfigKey
+	RemoteKubeconfigDir = "/remote"
+	RemoteKubeconfigKey = "kubeconfig"
 )
 
+// s3BucketNameRegexp matches DNS compatible bucket names
+var s3BucketNameRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
+
+// AzureAuthorityHosts are the login endpoints of the sovereign Azure clouds
+var AzureAuthorityHosts = map[string]string{
+	"AzureGovernment": "https://login.microsoftonline.us/",
+	"AzureChina":      "https://login.chinacloudapi.cn/",
+}
+
+// Analyzers are the names of the k8sgpt analyzers
+var Analyzers = []string{
+	"Pod", "Deployment", "ReplicaSet", "PersistentVolumeClaim", "Service", "Ingress",
+	"StatefulSet", "CronJob", "Node", "MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration", "HorizontalPodAutoscaler", "PodDisruptionBudget",
+	"NetworkPolicy", "Log", "GatewayClass", "Gateway", "HTTPRoute",
+}
+
+// GetTargetNamespace returns the namespace the K8sGPT workload is deployed to
+func GetTargetNamespace(config v1alpha1.K8sGPT) string {
+	if config.Spec.TargetNamespace != "" {
+		return config.Spec.TargetNamespace
+	}
+	return config.Namespace
+}
+
+// GetSpecHash returns a hash of the K8sGPT spec, shortened to fit into a label value
+func GetSpecHash(config v1alpha1.K8sGPT) (string, error) {
+	spec, er := json.Marshal(config.Spec)
+	if er != nil {
+		return "", er
+	}
+	sum := sha256.Sum256(spec)
+	return hex.EncodeToString(sum[:])[:32], nil
+}
+
+// ComputeOwnerReference returns the controller owner reference of the objects
+// managed for the K8sGPT instance
+func ComputeOwnerReference(config v1alpha1.K8sGPT) metav1.OwnerReference {
+	return metav1.OwnerReference{
+		Kind:               config.Kind,
+		Name:               config.Name,
+		UID:                config.UID,
+		APIVersion:         config.APIVersion,
+		BlockOwnerDeletion: utils.PtrBool(true),
+		Controller:         utils.PtrBool(true),
+	}
+}
+
 // GetService Create service for K8sGPT
 func GetService(config v1alpha1.K8sGPT) (*corev1.Service, error) {
 	// Create service
 	service := corev1.Service{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "k8sgpt",
-			Namespace: config.Namespace,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					Kind:               config.Kind,
-					Name:               config.Name,
-					UID:                config.UID,
-					APIVersion:         config.APIVersion,
-					BlockOwnerDeletion: utils.PtrBool(true),
-					Controller:         utils.PtrBool(true),
-				},
+			Namespace: GetTargetNamespace(config),
+			Labels: map[string]string{
+				"app": DeploymentName,
 			},
+			OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: map[string]string{
@@ -71,6 +145,29 @@ func GetService(config v1alpha1.K8sGPT) (*corev1.Service, error) {
 			},
 		},
 	}
+	if servicePort := config.Spec.ServicePort; servicePort != nil {
+		if servicePort.Port != 0 {
+			service.Spec.Ports[0].Port = servicePort.Port
+		}
+		service.Spec.Ports[0].TargetPort = servicePort.TargetPort
+		service.Spec.Ports[0].Protocol = servicePort.Protocol
+	}
+	if config.Spec.SessionAffinity != "" {
+		service.Spec.SessionAffinity = config.Spec.SessionAffinity
+	} else {
+		service.Spec.SessionAffinity = corev1.ServiceAffinityNone
+	}
+	if config.Spec.SessionAffinityConfig != nil {
+		service.Spec.SessionAffinityConfig = config.Spec.SessionAffinityConfig
+	}
+	// ports must be named once the service exposes more than one
+	if monitoringEnabled(config) {
+		service.Spec.Ports[0].Name = "grpc"
+		service.Spec.Ports = append(service.Spec.Ports, corev1.ServicePort{
+			Name: MetricsPortName,
+			Port: MetricsPort,
+		})
+	}
 
 	return &service, nil
 }
@@ -80,47 +177,45 @@ func GetServiceAccount(config v1alpha1.K8sGPT) (*corev1.ServiceAccount, error) {
 	// Create service account
 	serviceAccount := corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "k8sgpt",
-			Namespace: config.Namespace,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					Kind:               config.Kind,
-					Name:               config.Name,
-					UID:                config.UID,
-					APIVersion:         config.APIVersion,
-					BlockOwnerDeletion: utils.PtrBool(true),
-					Controller:         utils.PtrBool(true),
-				},
-			},
+			Name:            "k8sgpt",
+			Namespace:       GetTargetNamespace(config),
+			Annotations:     config.Spec.ServiceAccountAnnotations,
+			OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
 		},
+		AutomountServiceAccountToken: config.Spec.AutomountServiceAccountToken,
 	}
 
 	return &serviceAccount, nil
 }
 
+// GetResourceQuota Create ResourceQuota limiting the resources of the K8sGPT namespace
+func GetResourceQuota(config v1alpha1.K8sGPT) (*corev1.ResourceQuota, error) {
+	resourceQuota := corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "k8sgpt",
+			Namespace:       GetTargetNamespace(config),
+			OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
+		},
+		Spec: *config.Spec.ResourceQuota,
+	}
+
+	return &resourceQuota, nil
+}
+
 // GetClusterRoleBinding Create cluster role binding for K8sGPT
 func GetClusterRoleBinding(config v1alpha1.K8sGPT) (*r1.ClusterRoleBinding, error) {
 
 	// Create cluster role binding
 	clusterRoleBinding := r1.ClusterRoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "k8sgpt",
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					Kind:               config.Kind,
-					Name:               config.Name,
-					UID:                config.UID,
-					APIVersion:         config.APIVersion,
-					BlockOwnerDeletion: utils.PtrBool(true),
-					Controller:         utils.PtrBool(true),
-				},
-			},
+			Name:            "k8sgpt",
+			OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
 		},
 		Subjects: []r1.Subject{
 			{
 				Kind:      "ServiceAccount",
 				Name:      "k8sgpt",
-				Namespace: config.Namespace,
+				Namespace: GetTargetNamespace(config),
 			},
 		},
 		RoleRef: r1.RoleRef{
@@ -139,60 +234,195 @@ func GetClusterRole(config v1alpha1.K8sGPT) (*r1.ClusterRole, error) {
 	// Create cluster role
 	clusterRole := r1.ClusterRole{
 		ObjectMeta: metav1.ObjectMeta{
-			Name: "k8sgpt",
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					Kind:               config.Kind,
-					Name:               config.Name,
-					UID:                config.UID,
-					APIVersion:         config.APIVersion,
-					BlockOwnerDeletion: utils.PtrBool(true),
-					Controller:         utils.PtrBool(true),
-				},
-			},
+			Name:            "k8sgpt",
+			OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
 		},
 		Rules: []r1.PolicyRule{
-			{
-				APIGroups: []string{"*"},
-				Resources: []string{"*"},
-				// This is necessary for the creation of integrations
-				Verbs: []string{"create", "list", "get", "watch", "delete"},
-			},
 			// Allow creation of custom resources
 			{
 				APIGroups: []string{"apiextensions.k8s.io"},
 				Resources: []string{"*"},
 				Verbs:     []string{"*"},
 			},
+			// Results are listed explicitly, so that their mutations show up in audits
+			{
+				APIGroups: []string{v1alpha1.GroupVersion.Group},
+				Resources: []string{"results"},
+				Verbs:     []string{"get", "list", "watch", "create", "update", "patch"},
+			},
 		},
 	}
+	if len(config.Spec.WatchedNamespaces) == 0 {
+		clusterRole.Rules = append([]r1.PolicyRule{getAnalysisRule()}, clusterRole.Rules...)
+		if ephemeralContainersAllowed(config) {
+			clusterRole.Rules = append(clusterRole.Rules, getEphemeralContainersRule())
+		}
+	} else {
+		// nodes are cluster scoped, the Roles cannot grant them
+		clusterRole.Rules = append(clusterRole.Rules, r1.PolicyRule{
+			APIGroups: []string{""},
+			Resources: []string{"nodes"},
+			Verbs:     []string{"list", "get", "watch"},
+		})
+	}
+	clusterRole.Rules = append(clusterRole.Rules, config.Spec.ExtraClusterRoleRules...)
 
 	return &clusterRole, nil
 }
 
+// ownerLabels are the labels of the objects of the K8sGPT instance
+func ownerLabels(config v1alpha1.K8sGPT) map[string]string {
+	return map[string]string{
+		v1alpha1.K8sGPTNameLabel:      config.Name,
+		v1alpha1.K8sGPTNamespaceLabel: config.Namespace,
+	}
+}
+
+// GetRoles Create a Role for K8sGPT in each watched namespace. Roles of
+// namespaces removed from the watched namespaces are deleted by Sync.
+func GetRoles(config v1alpha1.K8sGPT) ([]*r1.Role, error) {
+	roles := make([]*r1.Role, 0, len(config.Spec.WatchedNamespaces))
+	rules := []r1.PolicyRule{getAnalysisRule()}
+	if ephemeralContainersAllowed(config) {
+		rules = append(rules, getEphemeralContainersRule())
+	}
+	for _, namespace := range config.Spec.WatchedNamespaces {
+		roles = append(roles, &r1.Role{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "k8sgpt",
+				Namespace:       namespace,
+				Labels:          ownerLabels(config),
+				OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
+			},
+			Rules: rules,
+		})
+	}
+
+	return roles, nil
+}
+
+// GetRoleBindings Create role bindings for the Roles of the watched namespaces
+func GetRoleBindings(config v1alpha1.K8sGPT) ([]*r1.RoleBinding, error) {
+	roleBindings := make([]*r1.RoleBinding, 0, len(config.Spec.WatchedNamespaces))
+	for _, namespace := range config.Spec.WatchedNamespaces {
+		roleBindings = append(roleBindings, &r1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "k8sgpt",
+				Namespace:       namespace,
+				Labels:          ownerLabels(config),
+				OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
+			},
+			Subjects: []r1.Subject{
+				{
+					Kind:      "ServiceAccount",
+					Name:      "k8sgpt",
+					Namespace: GetTargetNamespace(config),
+				},
+			},
+			RoleRef: r1.RoleRef{
+				Kind:     "Role",
+				Name:     "k8sgpt",
+				APIGroup: "rbac.authorization.k8s.io",
+			},
+		})
+	}
+
+	return roleBindings, nil
+}
+
+func ephemeralContainersAllowed(config v1alpha1.K8sGPT) bool {
+	return config.Spec.EphemeralContainerPolicy != nil && config.Spec.EphemeralContainerPolicy.Allowed
+}
+
+// getEphemeralContainersRule grants k8sgpt the injection of debug containers,
+// the analysis rule cannot update pods
+func getEphemeralContainersRule() r1.PolicyRule {
+	return r1.PolicyRule{
+		APIGroups: []string{""},
+		Resources: []string{"pods/ephemeralcontainers"},
+		Verbs:     []string{"get", "patch", "update"},
+	}
+}
+
+// getAnalysisRule grants k8sgpt access to the analyzed resources
+func getAnalysisRule() r1.PolicyRule {
+	return r1.PolicyRule{
+		APIGroups: []string{"*"},
+		Resources: []string{"*"},
+		// This is necessary for the creation of integrations
+		Verbs: []string{"create", "list", "get", "watch", "delete"},
+	}
+}
+
+// GetSensitiveClusterRoleRules returns the extra ClusterRole rules granting all
+// verbs on secrets or configmaps
+func GetSensitiveClusterRoleRules(config v1alpha1.K8sGPT) []r1.PolicyRule {
+	var sensitive []r1.PolicyRule
+	for _, rule := range config.Spec.ExtraClusterRoleRules {
+		if !utils.ContainsString(rule.Verbs, "*") {
+			continue
+		}
+		if !utils.ContainsString(rule.APIGroups, "") && !utils.ContainsString(rule.APIGroups, "*") {
+			continue
+		}
+		for _, resource := range []string{"*", "secrets", "configmaps"} {
+			if utils.ContainsString(rule.Resources, resource) {
+				sensitive = append(sensitive, rule)
+				break
+			}
+		}
+	}
+
+	return sensitive
+}
+
+// GetContainerSecurityContext returns the security context of the k8sgpt
+// container, the non-root default when the spec leaves it unset
+func GetContainerSecurityContext(config v1alpha1.K8sGPT) *corev1.SecurityContext {
+	if config.Spec.ContainerSecurityContext == nil {
+		return v1alpha1.DefaultContainerSecurityContext()
+	}
+	return config.Spec.ContainerSecurityContext
+}
+
+// GetImage returns the image of k8sgpt, the deprecated top-level fields are
+// used when the webhook has not moved them to Image yet
+func GetImage(config v1alpha1.K8sGPT) v1alpha1.ImageSpec {
+	image := v1alpha1.ImageSpec{
+		Repository: config.Spec.Repository,
+		Version:    config.Spec.Version,
+	}
+	if config.Spec.Image != nil {
+		if config.Spec.Image.Repository != "" {
+			image.Repository = config.Spec.Image.Repository
+		}
+		if config.Spec.Image.Version != "" {
+			image.Version = config.Spec.Image.Version
+		}
+	}
+	return image
+}
+
 // GetDeployment Create deployment with the latest K8sGPT image
 func GetDeployment(config v1alpha1.K8sGPT) (*appsv1.Deployment, error) {
-
 	// Create deployment
-	image := config.Spec.Repository + ":" + config.Spec.Version
+	image := GetImage(config)
+	specHash, er := GetSpecHash(config)
+	if er != nil {
+		return &appsv1.Deployment{}, er
+	}
 	replicas := int32(1)
 	deployment := appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      DeploymentName,
-			Namespace: config.Namespace,
-			OwnerReferences: []metav1.OwnerReference{
-				{
-					Kind:               config.Kind,
-					Name:               config.Name,
-					UID:                config.UID,
-					APIVersion:         config.APIVersion,
-					BlockOwnerDeletion: utils.PtrBool(true),
-					Controller:         utils.PtrBool(true),
-				},
-			},
+			Name:            DeploymentName,
+			Namespace:       GetTargetNamespace(config),
+			OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: &replicas,
+			Replicas:                &replicas,
+			MinReadySeconds:         config.Spec.MinReadySeconds,
+			ProgressDeadlineSeconds: config.Spec.ProgressDeadlineSeconds,
+			RevisionHistoryLimit:    config.Spec.RevisionHistoryLimit,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: map[string]string{
 					"app": DeploymentName,
@@ -201,16 +431,27 @@ func GetDeployment(config v1alpha1.K8sGPT) (*appsv1.Deployment, error) {
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: map[string]string{
-						"app": DeploymentName,
+						"app":         DeploymentName,
+						SpecHashLabel: specHash,
 					},
 				},
 				Spec: corev1.PodSpec{
-					ServiceAccountName: "k8sgpt",
+					ServiceAccountName:           "k8sgpt",
+					ShareProcessNamespace:        config.Spec.ShareProcessNamespace,
+					RuntimeClassName:             config.Spec.RuntimeClassName,
+					HostNetwork:                  config.Spec.HostNetwork,
+					HostAliases:                  config.Spec.HostAliases,
+					DNSPolicy:                    config.Spec.DNSPolicy,
+					DNSConfig:                    config.Spec.DNSConfig,
+					AutomountServiceAccountToken: config.Spec.AutomountServiceAccountToken,
+					ReadinessGates:               config.Spec.ReadinessGates,
 					Containers: []corev1.Container{
 						{
 							Name:            "k8sgpt",
 							ImagePullPolicy: corev1.PullAlways,
-							Image:           image,
+							Image:           image.Repository + ":" + image.Version,
+							WorkingDir:      config.Spec.WorkingDir,
+							SecurityContext: GetContainerSecurityContext(config),
 							Args: []string{
 								"serve",
 							},
@@ -265,6 +506,43 @@ func GetDeployment(config v1alpha1.K8sGPT) (*appsv1.Deployment, error) {
 			},
 		},
 	}
+	// the service targets the container port, k8sgpt has to listen on it
+	if servicePort := config.Spec.ServicePort; servicePort != nil {
+		targetPort := servicePort.TargetPort
+		if targetPort.Type == intstr.String && targetPort.StrVal != "" {
+			deployment.Spec.Template.Spec.Containers[0].Ports[0].Name = targetPort.StrVal
+		} else if targetPort.Type == intstr.Int && targetPort.IntVal != 0 {
+			deployment.Spec.Template.Spec.Containers[0].Ports[0].ContainerPort = targetPort.IntVal
+			deployment.Spec.Template.Spec.Containers[0].Args = append(
+				deployment.Spec.Template.Spec.Containers[0].Args, fmt.Sprintf("--port=%d", targetPort.IntVal),
+			)
+		}
+		deployment.Spec.Template.Spec.Containers[0].Ports[0].Protocol = servicePort.Protocol
+	}
+	// cluster services must still be resolvable from the host network, the
+	// policy defaults to ClusterFirst, which falls back to the node
+	if config.Spec.HostNetwork &&
+		(config.Spec.DNSPolicy == "" || config.Spec.DNSPolicy == corev1.DNSClusterFirst) {
+		deployment.Spec.Template.Spec.DNSPolicy = corev1.DNSClusterFirstWithHostNet
+	}
+	deployment.Spec.Template.Spec.Containers[0].StartupProbe = config.Spec.StartupProbe
+	if config.Spec.StartupProbe == nil {
+		deployment.Spec.Template.Spec.Containers[0].StartupProbe = v1alpha1.DefaultStartupProbe()
+	}
+	deployment.Spec.Template.Spec.Containers[0].LivenessProbe = config.Spec.LivenessProbe
+	if len(config.Spec.EnvFrom) > 0 {
+		deployment.Spec.Template.Spec.Containers[0].EnvFrom = append(
+			deployment.Spec.Template.Spec.Containers[0].EnvFrom, config.Spec.EnvFrom...,
+		)
+	}
+	if monitoringEnabled(config) {
+		deployment.Spec.Template.Spec.Containers[0].Ports = append(
+			deployment.Spec.Template.Spec.Containers[0].Ports, corev1.ContainerPort{
+				Name:          MetricsPortName,
+				ContainerPort: MetricsPort,
+			},
+		)
+	}
 	if config.Spec.AI.Secret != nil {
 		password := corev1.EnvVar{
 			Name: "K8SGPT_PASSWORD",
@@ -282,7 +560,7 @@ func GetDeployment(config v1alpha1.K8sGPT) (*appsv1.Deployment, error) {
 		)
 	}
 	if config.Spec.RemoteCache != nil {
-
+		// only a single remote cache backend can be configured at a time
 		// check to see if key/value exists
 		addRemoteCacheEnvVar := func(name, key string) {
 			envVar := v1.EnvVar{
@@ -301,136 +579,1286 @@ func GetDeployment(config v1alpha1.K8sGPT) (*appsv1.Deployment, error) {
 			)
 		}
 		if config.Spec.RemoteCache.Azure != nil {
+			// the names are passed to k8sgpt with the AddConfig call
 			addRemoteCacheEnvVar("AZURE_CLIENT_ID", "azure_client_id")
 			addRemoteCacheEnvVar("AZURE_TENANT_ID", "azure_tenant_id")
 			addRemoteCacheEnvVar("AZURE_CLIENT_SECRET", "azure_client_secret")
+			if config.Spec.RemoteCache.Azure.BlobEndpoint != "" {
+				deployment.Spec.Template.Spec.Containers[0].Env = append(
+					deployment.Spec.Template.Spec.Containers[0].Env,
+					corev1.EnvVar{
+						Name:  "AZURE_BLOB_ENDPOINT",
+						Value: config.Spec.RemoteCache.Azure.BlobEndpoint,
+					},
+				)
+			}
+			if environment := config.Spec.RemoteCache.Azure.CloudEnvironment; environment != "" {
+				deployment.Spec.Template.Spec.Containers[0].Env = append(
+					deployment.Spec.Template.Spec.Containers[0].Env,
+					corev1.EnvVar{
+						Name:  "AZURE_ENVIRONMENT",
+						Value: environment,
+					},
+				)
+				// the credentials are issued by the login endpoint of the cloud
+				if authorityHost, ok := AzureAuthorityHosts[environment]; ok {
+					deployment.Spec.Template.Spec.Containers[0].Env = append(
+						deployment.Spec.Template.Spec.Containers[0].Env,
+						corev1.EnvVar{
+							Name:  "AZURE_AUTHORITY_HOST",
+							Value: authorityHost,
+						},
+					)
+				}
+			}
 		} else if config.Spec.RemoteCache.S3 != nil {
 			addRemoteCacheEnvVar("AWS_ACCESS_KEY_ID", "aws_access_key_id")
 			addRemoteCacheEnvVar("AWS_SECRET_ACCESS_KEY", "aws_secret_access_key")
+			// the bucket is passed to k8sgpt with the AddConfig call
+			if config.Spec.RemoteCache.S3.Prefix != "" {
+				deployment.Spec.Template.Spec.Containers[0].Env = append(
+					deployment.Spec.Template.Spec.Containers[0].Env,
+					corev1.EnvVar{
+						Name:  "K8SGPT_S3_PREFIX",
+						Value: config.Spec.RemoteCache.S3.Prefix,
+					},
+				)
+			}
+			if config.Spec.RemoteCache.S3.Endpoint != "" {
+				deployment.Spec.Template.Spec.Containers[0].Env = append(
+					deployment.Spec.Template.Spec.Containers[0].Env,
+					corev1.EnvVar{
+						Name:  "K8SGPT_S3_ENDPOINT",
+						Value: config.Spec.RemoteCache.S3.Endpoint,
+					},
+				)
+			}
+			if config.Spec.RemoteCache.S3.InsecureSkipVerify {
+				deployment.Spec.Template.Spec.Containers[0].Env = append(
+					deployment.Spec.Template.Spec.Containers[0].Env,
+					corev1.EnvVar{
+						Name:  "K8SGPT_S3_INSECURE_SKIP_VERIFY",
+						Value: "true",
+					},
+				)
+			}
+		} else if config.Spec.RemoteCache.Redis != nil {
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name:  "K8SGPT_CACHE_TYPE",
+					Value: "redis",
+				},
+				corev1.EnvVar{
+					Name:  "K8SGPT_REDIS_ADDRESS",
+					Value: config.Spec.RemoteCache.Redis.Address,
+				},
+			)
+			// the password is optional for redis and has a secret of its own
+			if credentials := config.Spec.RemoteCache.Redis.Credentials; credentials != nil {
+				deployment.Spec.Template.Spec.Containers[0].Env = append(
+					deployment.Spec.Template.Spec.Containers[0].Env,
+					corev1.EnvVar{
+						Name: "K8SGPT_REDIS_PASSWORD",
+						ValueFrom: &corev1.EnvVarSource{
+							SecretKeyRef: &corev1.SecretKeySelector{
+								LocalObjectReference: *credentials,
+								Key:                  "redis_password",
+							},
+						},
+					},
+				)
+			}
+		}
+		if config.Spec.RemoteCache.EncryptionKey != nil {
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name: "K8SGPT_CACHE_ENCRYPTION_KEY",
+					ValueFrom: &corev1.EnvVarSource{
+						SecretKeyRef: config.Spec.RemoteCache.EncryptionKey,
+					},
+				},
+			)
+		}
+		if ttl := config.Spec.RemoteCache.TTL; ttl != nil {
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name:  "K8SGPT_CACHE_TTL",
+					Value: fmt.Sprint(int64(ttl.Seconds())),
+				},
+			)
 		}
 	}
 
-	if config.Spec.AI.BaseUrl != "" {
-		baseUrl := corev1.EnvVar{
-			Name:  "K8SGPT_BASEURL",
-			Value: config.Spec.AI.BaseUrl,
+	if observability := config.Spec.Observability; observability != nil {
+		if observability.OTLPEndpoint != "" {
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name:  "OTEL_EXPORTER_OTLP_ENDPOINT",
+					Value: observability.OTLPEndpoint,
+				},
+			)
+		}
+		if len(observability.OTLPHeaders) > 0 {
+			// sorted, so that the environment does not change between syncs
+			headers := make([]string, 0, len(observability.OTLPHeaders))
+			for header, value := range observability.OTLPHeaders {
+				headers = append(headers, header+"="+value)
+			}
+			sort.Strings(headers)
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name:  "OTEL_EXPORTER_OTLP_HEADERS",
+					Value: strings.Join(headers, ","),
+				},
+			)
+		} else if observability.OTLPHeadersSecretRef != nil {
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name: "OTEL_EXPORTER_OTLP_HEADERS",
+					ValueFrom: &corev1.EnvVarSource{
+						SecretKeyRef: observability.OTLPHeadersSecretRef,
+					},
+				},
+			)
+		}
+		if observability.ServiceName != "" {
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name:  "OTEL_SERVICE_NAME",
+					Value: observability.ServiceName,
+				},
+			)
 		}
+	}
+
+	if config.Spec.GRPCMaxMessageSizeMB != 0 {
 		deployment.Spec.Template.Spec.Containers[0].Env = append(
-			deployment.Spec.Template.Spec.Containers[0].Env, baseUrl,
+			deployment.Spec.Template.Spec.Containers[0].Env,
+			corev1.EnvVar{
+				Name:  "K8SGPT_GRPC_MAX_MSG_SIZE",
+				Value: fmt.Sprint(config.Spec.GRPCMaxMessageSizeMB),
+			},
 		)
 	}
-	// Engine is required only when azureopenai is the ai backend
-	if config.Spec.AI.Engine != "" && config.Spec.AI.Backend == v1alpha1.AzureOpenAI {
-		engine := corev1.EnvVar{
-			Name:  "K8SGPT_ENGINE",
-			Value: config.Spec.AI.Engine,
+	if runtimeTuning := config.Spec.RuntimeTuning; runtimeTuning != nil {
+		if runtimeTuning.GoDebug != "" {
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name:  "GODEBUG",
+					Value: runtimeTuning.GoDebug,
+				},
+			)
+		}
+		if runtimeTuning.GoGC != nil {
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name:  "GOGC",
+					Value: fmt.Sprint(*runtimeTuning.GoGC),
+				},
+			)
 		}
+	}
+	// k8sgpt analyzes the cluster of the kubeconfig instead of its own
+	if remoteKubeconfig := config.Spec.RemoteKubeconfig; remoteKubeconfig != nil {
+		deployment.Spec.Template.Spec.Volumes = append(deployment.Spec.Template.Spec.Volumes,
+			corev1.Volume{
+				Name: "k8sgpt-remote-kubeconfig",
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: remoteKubeconfig.Name},
+				},
+			},
+		)
+		deployment.Spec.Template.Spec.Containers[0].VolumeMounts = append(
+			deployment.Spec.Template.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+				Name:      "k8sgpt-remote-kubeconfig",
+				MountPath: RemoteKubeconfigDir,
+				ReadOnly:  true,
+			},
+		)
 		deployment.Spec.Template.Spec.Containers[0].Env = append(
-			deployment.Spec.Template.Spec.Containers[0].Env, engine,
+			deployment.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+				Name:  "KUBECONFIG",
+				Value: path.Join(RemoteKubeconfigDir, RemoteKubeconfigKey),
+			},
 		)
-	} else if config.Spec.AI.Engine != "" && config.Spec.AI.Backend != v1alpha1.AzureOpenAI {
-		return &appsv1.Deployment{}, err.New("Engine is supported only by azureopenai provider.")
 	}
-	return &deployment, nil
-}
-
-func Sync(ctx context.Context, c client.Client,
-	config v1alpha1.K8sGPT, i SyncOrDestroy) error {
-
-	var objs []client.Object
-
-	svc, er := GetService(config)
-	if er != nil {
-		return er
+	if config.Spec.AI.ReasoningEffort != "" {
+		reasoningEffort := corev1.EnvVar{
+			Name:  "K8SGPT_REASONING_EFFORT",
+			Value: config.Spec.AI.ReasoningEffort,
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, reasoningEffort,
+		)
 	}
-
-	objs = append(objs, svc)
-
-	svcAcc, er := GetServiceAccount(config)
-	if er != nil {
-		return er
+	// sorted, so that the environment does not change between syncs
+	headers := make([]string, 0, len(config.Spec.AI.ExtraHeaders))
+	for header := range config.Spec.AI.ExtraHeaders {
+		headers = append(headers, header)
 	}
-
-	objs = append(objs, svcAcc)
-
-	clusterRole, er := GetClusterRole(config)
-	if er != nil {
-		return er
+	sort.Strings(headers)
+	for _, header := range headers {
+		extraHeader := corev1.EnvVar{
+			Name:  ExtraHeaderEnvPrefix + extraHeaderEnvName(header),
+			Value: config.Spec.AI.ExtraHeaders[header],
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, extraHeader,
+		)
 	}
-
-	objs = append(objs, clusterRole)
-
-	clusterRoleBinding, er := GetClusterRoleBinding(config)
-	if er != nil {
-		return er
+	for _, plugin := range config.Spec.AI.Plugins {
+		prefix := "K8SGPT_PLUGIN_" + strings.ToUpper(plugin.Name)
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env,
+			corev1.EnvVar{
+				Name:  prefix + "_ENABLED",
+				Value: strconv.FormatBool(plugin.Enabled),
+			},
+		)
+		// sorted, so that the environment does not change between syncs
+		keys := make([]string, 0, len(plugin.Config))
+		for key := range plugin.Config {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name:  prefix + "_CONFIG_" + extraHeaderEnvName(key),
+					Value: plugin.Config[key],
+				},
+			)
+		}
 	}
-
-	objs = append(objs, clusterRoleBinding)
-
-	deployment, er := GetDeployment(config)
-	if er != nil {
-		return er
+	analyzers := make([]string, 0, len(config.Spec.AI.ModelOverridePerAnalyzer))
+	for analyzer := range config.Spec.AI.ModelOverridePerAnalyzer {
+		analyzers = append(analyzers, analyzer)
 	}
-
-	objs = append(objs, deployment)
-
-	// for each object, create or destroy
-	for _, obj := range objs {
-		switch i {
-		case SyncOp:
-
-			// before creation, we will check to see if the secret exists if used as a ref
-			if config.Spec.AI.Secret != nil {
-
-				secret := &corev1.Secret{}
-				er := c.Get(ctx, types.NamespacedName{Name: config.Spec.AI.Secret.Name,
-					Namespace: config.Namespace}, secret)
-				if er != nil {
-					return err.New("references secret does not exist, cannot create deployment")
-				}
-			}
-
-			err := doSync(ctx, c, obj)
-			if err != nil {
-				// If the object already exists, ignore the error
-				if !errors.IsAlreadyExists(err) {
-					return err
-				}
-			}
-		case DestroyOp:
-			err := c.Delete(ctx, obj)
-			if err != nil {
-				// if the object is not found, ignore the error
-				if !errors.IsNotFound(err) {
-					return err
-				}
-			}
+	sort.Strings(analyzers)
+	for _, analyzer := range analyzers {
+		modelOverride := corev1.EnvVar{
+			Name:  "K8SGPT_MODEL_OVERRIDE_" + strings.ToUpper(analyzer),
+			Value: config.Spec.AI.ModelOverridePerAnalyzer[analyzer],
 		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, modelOverride,
+		)
 	}
-
-	return nil
-}
-
-func doSync(ctx context.Context, clt client.Client, obj client.Object) error {
-	var mutateFn controllerutil.MutateFn
-	switch expect := obj.(type) {
-	case *appsv1.Deployment:
-		exist := &appsv1.Deployment{}
-		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
-		if err != nil && !errors.IsNotFound(err) {
-			return err
-		} else if err == nil {
-			mutateFn = func() error {
-				exist.Spec = expect.Spec
-				return nil
-			}
-			obj = exist
+	if config.Spec.AI.ContextWindow != 0 {
+		contextWindow := corev1.EnvVar{
+			Name:  "K8SGPT_CONTEXT_WINDOW",
+			Value: fmt.Sprint(config.Spec.AI.ContextWindow),
 		}
-	case *corev1.Service:
-		exist := &corev1.Service{}
-		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, contextWindow,
+		)
+	}
+	if config.Spec.AI.FunctionCalling {
+		functionCalling := corev1.EnvVar{
+			Name:  "K8SGPT_FUNCTION_CALLING",
+			Value: "true",
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, functionCalling,
+		)
+	}
+	if config.Spec.AI.OutputFormat != "" {
+		outputFormat := corev1.EnvVar{
+			Name:  "K8SGPT_OUTPUT_FORMAT",
+			Value: config.Spec.AI.OutputFormat,
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, outputFormat,
+		)
+	}
+	if config.Spec.AI.TopP != nil {
+		topP := corev1.EnvVar{
+			Name:  "K8SGPT_TOP_P",
+			Value: *config.Spec.AI.TopP,
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, topP,
+		)
+	}
+	if config.Spec.AI.SeedValue != nil {
+		seed := corev1.EnvVar{
+			Name:  "K8SGPT_SEED",
+			Value: fmt.Sprint(*config.Spec.AI.SeedValue),
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, seed,
+		)
+	}
+	if config.Spec.AI.LocalModelPath != "" {
+		mountLocalModel(config, &deployment)
+	}
+	if config.Spec.AI.ConversationHistory {
+		conversationHistory := corev1.EnvVar{
+			Name:  "K8SGPT_CONVERSATION_HISTORY",
+			Value: "true",
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, conversationHistory,
+		)
+	}
+	if responseTimeout := config.Spec.AI.ResponseTimeout; responseTimeout != nil {
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+				Name:  "K8SGPT_RESPONSE_TIMEOUT",
+				Value: fmt.Sprint(int64(responseTimeout.Seconds())),
+			},
+		)
+	}
+	if config.Spec.AI.DisableTelemetry {
+		disableTelemetry := corev1.EnvVar{
+			Name:  "K8SGPT_DISABLE_TELEMETRY",
+			Value: "true",
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, disableTelemetry,
+		)
+	}
+	// unset, k8sgpt uses its own default
+	if config.Spec.AI.BatchSize != 0 {
+		batchSize := corev1.EnvVar{
+			Name:  "K8SGPT_BATCH_SIZE",
+			Value: fmt.Sprint(config.Spec.AI.BatchSize),
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, batchSize,
+		)
+	}
+	if len(config.Spec.AI.BackendFallback) > 0 {
+		fallbacks := make([]string, 0, len(config.Spec.AI.BackendFallback))
+		for _, backend := range config.Spec.AI.BackendFallback {
+			fallbacks = append(fallbacks, string(backend))
+		}
+		fallback := corev1.EnvVar{
+			Name:  "K8SGPT_BACKEND_FALLBACK",
+			Value: strings.Join(fallbacks, ","),
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, fallback,
+		)
+	}
+	if retryPolicy := config.Spec.AI.RetryPolicy; retryPolicy != nil {
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env,
+			corev1.EnvVar{
+				Name:  "K8SGPT_MAX_RETRIES",
+				Value: fmt.Sprint(retryPolicy.MaxRetries),
+			},
+		)
+		if retryPolicy.RetryDelay != nil {
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name:  "K8SGPT_RETRY_DELAY",
+					Value: retryPolicy.RetryDelay.Duration.String(),
+				},
+			)
+		}
+	}
+	if config.Spec.AI.PromptTemplate != "" {
+		// URL-encoded, the template spans multiple lines and contains quotes
+		promptTemplate := corev1.EnvVar{
+			Name:  "K8SGPT_PROMPT_TEMPLATE",
+			Value: url.QueryEscape(config.Spec.AI.PromptTemplate),
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, promptTemplate,
+		)
+	}
+	if config.Spec.NoCache {
+		noCache := corev1.EnvVar{
+			Name:  "K8SGPT_NO_CACHE",
+			Value: "true",
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, noCache,
+		)
+	} else if config.Spec.AI.CacheResults {
+		cache := corev1.EnvVar{
+			Name:  "K8SGPT_CACHE",
+			Value: "true",
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, cache,
+		)
+	}
+
+	baseUrlValue := config.Spec.AI.BaseUrl
+	// Groq and Mistral serve an OpenAI compatible API, their base url can be overridden
+	if baseUrlValue == "" && config.Spec.AI.BaseUrlSecretRef == nil {
+		switch config.Spec.AI.Backend {
+		case v1alpha1.Groq:
+			baseUrlValue = GroqBaseUrl
+		case v1alpha1.Mistral:
+			baseUrlValue = MistralBaseUrl
+		}
+	}
+	if baseUrlValue != "" {
+		baseUrl := corev1.EnvVar{
+			Name:  "K8SGPT_BASEURL",
+			Value: baseUrlValue,
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, baseUrl,
+		)
+	} else if config.Spec.AI.BaseUrlSecretRef != nil {
+		baseUrl := corev1.EnvVar{
+			Name: "K8SGPT_BASEURL",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: config.Spec.AI.BaseUrlSecretRef,
+			},
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, baseUrl,
+		)
+	}
+	// the labels set by the operator select the pod, they are never overwritten
+	for k, v := range config.Spec.PodLabels {
+		if _, ok := deployment.Spec.Template.Labels[k]; !ok {
+			deployment.Spec.Template.Labels[k] = v
+		}
+	}
+	deployment.Spec.Strategy = *config.Spec.UpdateStrategy.DeepCopy()
+	if config.Spec.MaxSurge != nil || config.Spec.MaxUnavailable != nil {
+		deployment.Spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+		if deployment.Spec.Strategy.RollingUpdate == nil {
+			deployment.Spec.Strategy.RollingUpdate = &appsv1.RollingUpdateDeployment{}
+		}
+		if config.Spec.MaxSurge != nil {
+			deployment.Spec.Strategy.RollingUpdate.MaxSurge = config.Spec.MaxSurge
+		}
+		if config.Spec.MaxUnavailable != nil {
+			deployment.Spec.Strategy.RollingUpdate.MaxUnavailable = config.Spec.MaxUnavailable
+		}
+	}
+	if config.Spec.AI.Language != "" {
+		language := corev1.EnvVar{
+			Name:  "K8SGPT_LANGUAGE",
+			Value: config.Spec.AI.Language,
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, language,
+		)
+	}
+	if len(config.Spec.WatchedNamespaces) > 0 {
+		namespaces := corev1.EnvVar{
+			Name:  "K8SGPT_NAMESPACES",
+			Value: strings.Join(config.Spec.WatchedNamespaces, ","),
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, namespaces,
+		)
+	}
+	// Engine is required only when azureopenai is the ai backend
+	if config.Spec.AI.Engine != "" && config.Spec.AI.Backend == v1alpha1.AzureOpenAI {
+		engine := corev1.EnvVar{
+			Name:  "K8SGPT_ENGINE",
+			Value: config.Spec.AI.Engine,
+		}
+		deployment.Spec.Template.Spec.Containers[0].Env = append(
+			deployment.Spec.Template.Spec.Containers[0].Env, engine,
+		)
+	}
+	// APIVersion is used only when azureopenai is the ai backend, k8sgpt
+	// falls back to its default api version without it
+	if config.Spec.AI.Backend == v1alpha1.AzureOpenAI {
+		if config.Spec.AI.APIVersion != "" {
+			apiVersion := corev1.EnvVar{
+				Name:  "K8SGPT_AZURE_API_VERSION",
+				Value: config.Spec.AI.APIVersion,
+			}
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env, apiVersion,
+			)
+		}
+		if azureAD := config.Spec.AI.AzureAD; azureAD != nil {
+			deployment.Spec.Template.Spec.Containers[0].Env = append(
+				deployment.Spec.Template.Spec.Containers[0].Env,
+				corev1.EnvVar{
+					Name:  "AZURE_TENANT_ID",
+					Value: azureAD.TenantID,
+				},
+				corev1.EnvVar{
+					Name:  "AZURE_CLIENT_ID",
+					Value: azureAD.ClientID,
+				},
+			)
+			if azureAD.ClientSecretRef != nil {
+				deployment.Spec.Template.Spec.Containers[0].Env = append(
+					deployment.Spec.Template.Spec.Containers[0].Env,
+					corev1.EnvVar{
+						Name: "AZURE_CLIENT_SECRET",
+						ValueFrom: &corev1.EnvVarSource{
+							SecretKeyRef: azureAD.ClientSecretRef,
+						},
+					},
+				)
+			}
+		}
+	}
+	return &deployment, nil
+}
+
+// extraHeaderEnvName maps a header name to an environment variable name,
+// e.g. X-API-Key to X_API_KEY
+func extraHeaderEnvName(header string) string {
+	return strings.ToUpper(strings.ReplaceAll(header, "-", "_"))
+}
+
+// addSecretExtraHeaders references each key of the extra headers secret, the
+// keys are header names and mapped like the ones of spec.ai.extraHeaders
+func addSecretExtraHeaders(podSpec *corev1.PodSpec, secret *corev1.Secret) {
+	// sorted, so that the environment does not change between syncs
+	headers := make([]string, 0, len(secret.Data))
+	for header := range secret.Data {
+		headers = append(headers, header)
+	}
+	sort.Strings(headers)
+	for _, header := range headers {
+		podSpec.Containers[0].Env = append(podSpec.Containers[0].Env, corev1.EnvVar{
+			Name: ExtraHeaderEnvPrefix + extraHeaderEnvName(header),
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+					Key:                  header,
+				},
+			},
+		})
+	}
+}
+
+// backendRequiresSecret reports whether the AI backend authenticates with an API key
+func backendRequiresSecret(backend string) bool {
+	switch backend {
+	case v1alpha1.OpenAI, v1alpha1.AzureOpenAI, v1alpha1.Cohere, v1alpha1.Anthropic, v1alpha1.Groq, v1alpha1.Mistral:
+		return true
+	}
+	return false
+}
+
+// GetObjects returns all the objects managed for the K8sGPT instance
+func GetObjects(config v1alpha1.K8sGPT) ([]client.Object, error) {
+
+	var objs []client.Object
+
+	svc, er := GetService(config)
+	if er != nil {
+		return nil, er
+	}
+
+	objs = append(objs, svc)
+
+	svcAcc, er := GetServiceAccount(config)
+	if er != nil {
+		return nil, er
+	}
+
+	objs = append(objs, svcAcc)
+
+	if config.Spec.ResourceQuota != nil {
+		resourceQuota, er := GetResourceQuota(config)
+		if er != nil {
+			return nil, er
+		}
+
+		objs = append(objs, resourceQuota)
+	}
+
+	clusterRole, er := GetClusterRole(config)
+	if er != nil {
+		return nil, er
+	}
+
+	objs = append(objs, clusterRole)
+
+	clusterRoleBinding, er := GetClusterRoleBinding(config)
+	if er != nil {
+		return nil, er
+	}
+
+	objs = append(objs, clusterRoleBinding)
+
+	roles, er := GetRoles(config)
+	if er != nil {
+		return nil, er
+	}
+
+	for _, role := range roles {
+		objs = append(objs, role)
+	}
+
+	roleBindings, er := GetRoleBindings(config)
+	if er != nil {
+		return nil, er
+	}
+
+	for _, roleBinding := range roleBindings {
+		objs = append(objs, roleBinding)
+	}
+
+	if scheduledAnalysisEnabled(config) {
+		cronJob, er := GetCronJob(config)
+		if er != nil {
+			return nil, er
+		}
+
+		objs = append(objs, cronJob)
+	} else {
+		deployment, er := GetDeployment(config)
+		if er != nil {
+			return nil, er
+		}
+
+		objs = append(objs, deployment)
+	}
+
+	if monitoringEnabled(config) {
+		// exactly one of them scrapes the metrics
+		if config.Spec.Monitoring.PodMonitor {
+			podMonitor, er := GetPodMonitor(config)
+			if er != nil {
+				return nil, er
+			}
+
+			objs = append(objs, podMonitor)
+		} else {
+			serviceMonitor, er := GetServiceMonitor(config)
+			if er != nil {
+				return nil, er
+			}
+
+			objs = append(objs, serviceMonitor)
+		}
+	}
+
+	if networkPolicyEnabled(config) {
+		networkPolicy, er := GetNetworkPolicy(config)
+		if er != nil {
+			return nil, er
+		}
+
+		objs = append(objs, networkPolicy)
+	}
+
+	if alertingRulesEnabled(config) {
+		prometheusRule, er := GetPrometheusRule(config)
+		if er != nil {
+			return nil, er
+		}
+
+		objs = append(objs, prometheusRule)
+	}
+
+	if vpaEnabled(config) {
+		vpa, er := GetVerticalPodAutoscaler(config)
+		if er != nil {
+			return nil, er
+		}
+
+		objs = append(objs, vpa)
+	}
+
+	// Owner references across namespaces are not allowed, the garbage collector
+	// would remove such objects. They are cleaned up by the finalizer instead.
+	for _, obj := range objs {
+		if obj.GetNamespace() != "" && obj.GetNamespace() != config.Namespace {
+			obj.SetOwnerReferences(nil)
+		}
+	}
+
+	return objs, nil
+}
+
+// SyncOperation describes what Sync did with an object
+type SyncOperation string
+
+const (
+	SyncCreated   SyncOperation = "Created"
+	SyncUpdated   SyncOperation = "Updated"
+	SyncUnchanged SyncOperation = "Unchanged"
+	SyncDeleted   SyncOperation = "Deleted"
+	// SyncSkipped objects belong to an optional integration whose CRD is not installed
+	SyncSkipped SyncOperation = "Skipped"
+	SyncFailed  SyncOperation = "Failed"
+)
+
+// ResourceSyncResult is the outcome of Sync for one of the managed objects
+type ResourceSyncResult struct {
+	ObjectRef corev1.ObjectReference
+	Operation SyncOperation
+	Error     error
+}
+
+func newResourceSyncResult(c client.Client, obj client.Object, operation SyncOperation, er error) ResourceSyncResult {
+	objectRef := corev1.ObjectReference{
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+	// typed objects do not carry their kind
+	if gvk, er := apiutil.GVKForObject(obj, c.Scheme()); er == nil {
+		objectRef.APIVersion, objectRef.Kind = gvk.ToAPIVersionAndKind()
+	}
+	return ResourceSyncResult{ObjectRef: objectRef, Operation: operation, Error: er}
+}
+
+// Sync creates, updates or destroys the objects of the K8sGPT instance
+func Sync(ctx context.Context, c client.Client,
+	config v1alpha1.K8sGPT, i SyncOrDestroy) error {
+	_, er := SyncWithResults(ctx, c, config, i)
+	return er
+}
+
+// SyncWithResults is Sync, it returns the outcome for each of the objects it
+// got to, including the one that failed
+func SyncWithResults(ctx context.Context, c client.Client,
+	config v1alpha1.K8sGPT, i SyncOrDestroy) ([]ResourceSyncResult, error) {
+	var results []ResourceSyncResult
+
+	// all problems of the spec are reported at once. An invalid spec, e.g. after
+	// a stricter check was added, must not keep the objects from being destroyed.
+	if i == SyncOp {
+		if errs := ValidateConfig(config); len(errs) > 0 {
+			return nil, utilerrors.NewAggregate(errs)
+		}
+	}
+
+	objs, er := GetObjects(config)
+	if er != nil {
+		return nil, er
+	}
+
+	// before creation, we will check to see if the namespaces exist when they differ
+	if i == SyncOp && GetTargetNamespace(config) != config.Namespace {
+		for _, name := range []string{config.Namespace, GetTargetNamespace(config)} {
+			namespace := &corev1.Namespace{}
+			er := c.Get(ctx, types.NamespacedName{Name: name}, namespace)
+			if er != nil {
+				return nil, fmt.Errorf("namespace %s does not exist, cannot create deployment", name)
+			}
+		}
+	}
+
+	// before creation, we will check to see if the watched namespaces exist
+	if i == SyncOp {
+		for _, name := range config.Spec.WatchedNamespaces {
+			namespace := &corev1.Namespace{}
+			er := c.Get(ctx, types.NamespacedName{Name: name}, namespace)
+			if er != nil {
+				return nil, fmt.Errorf("watched namespace %s does not exist, cannot create role", name)
+			}
+		}
+	}
+
+	// before creation, we will check to see if the runtime class exists, its
+	// overhead is added to the pod by the RuntimeClass admission controller
+	if i == SyncOp && config.Spec.RuntimeClassName != nil {
+		runtimeClass := &nodev1.RuntimeClass{}
+		er := c.Get(ctx, types.NamespacedName{Name: *config.Spec.RuntimeClassName}, runtimeClass)
+		if er != nil {
+			return nil, fmt.Errorf("runtime class %s does not exist, cannot create deployment", *config.Spec.RuntimeClassName)
+		}
+	}
+
+	// before creation, we will check to see if the encryption key secret exists
+	if i == SyncOp && config.Spec.RemoteCache != nil && config.Spec.RemoteCache.EncryptionKey != nil {
+		secret := &corev1.Secret{}
+		er := c.Get(ctx, types.NamespacedName{Name: config.Spec.RemoteCache.EncryptionKey.Name,
+			Namespace: GetTargetNamespace(config)}, secret)
+		if er != nil {
+			return nil, err.New("references encryption key secret does not exist, cannot create deployment")
+		}
+	}
+
+	// before creation, we will check to see if the base url secret exists
+	if i == SyncOp && config.Spec.AI.BaseUrlSecretRef != nil {
+		secret := &corev1.Secret{}
+		er := c.Get(ctx, types.NamespacedName{Name: config.Spec.AI.BaseUrlSecretRef.Name,
+			Namespace: GetTargetNamespace(config)}, secret)
+		if er != nil {
+			return nil, err.New("references base url secret does not exist, cannot create deployment")
+		}
+	}
+
+	// before creation, we will check to see if the azure ad client secret exists
+	if i == SyncOp && config.Spec.AI.AzureAD != nil && config.Spec.AI.AzureAD.ClientSecretRef != nil {
+		secret := &corev1.Secret{}
+		er := c.Get(ctx, types.NamespacedName{Name: config.Spec.AI.AzureAD.ClientSecretRef.Name,
+			Namespace: GetTargetNamespace(config)}, secret)
+		if er != nil {
+			return nil, err.New("references azure ad client secret does not exist, cannot create deployment")
+		}
+	}
+
+	// before creation, we will check to see if the extra headers secret exists.
+	// GetDeployment cannot read its keys, the headers are added here.
+	if i == SyncOp && config.Spec.AI.ExtraHeadersSecretRef != nil {
+		secret := &corev1.Secret{}
+		er := c.Get(ctx, types.NamespacedName{Name: config.Spec.AI.ExtraHeadersSecretRef.Name,
+			Namespace: GetTargetNamespace(config)}, secret)
+		if er != nil {
+			return nil, err.New("references extra headers secret does not exist, cannot create deployment")
+		}
+		for _, obj := range objs {
+			switch workload := obj.(type) {
+			case *appsv1.Deployment:
+				addSecretExtraHeaders(&workload.Spec.Template.Spec, secret)
+			case *batchv1.CronJob:
+				addSecretExtraHeaders(&workload.Spec.JobTemplate.Spec.Template.Spec, secret)
+			}
+		}
+	}
+
+	// before creation, we will check to see if the remote kubeconfig secret exists
+	if i == SyncOp && config.Spec.RemoteKubeconfig != nil {
+		secret := &corev1.Secret{}
+		er := c.Get(ctx, types.NamespacedName{Name: config.Spec.RemoteKubeconfig.Name,
+			Namespace: GetTargetNamespace(config)}, secret)
+		if er != nil {
+			return nil, err.New("references remote kubeconfig secret does not exist, cannot create deployment")
+		}
+		if _, ok := secret.Data[RemoteKubeconfigKey]; !ok {
+			return nil, fmt.Errorf("remote kubeconfig secret has no key %s, cannot create deployment", RemoteKubeconfigKey)
+		}
+	}
+
+	// before creation, we will check to see if the otlp headers secret exists
+	if i == SyncOp && config.Spec.Observability != nil && config.Spec.Observability.OTLPHeadersSecretRef != nil {
+		secret := &corev1.Secret{}
+		er := c.Get(ctx, types.NamespacedName{Name: config.Spec.Observability.OTLPHeadersSecretRef.Name,
+			Namespace: GetTargetNamespace(config)}, secret)
+		if er != nil {
+			return nil, err.New("references otlp headers secret does not exist, cannot create deployment")
+		}
+	}
+
+	// before creation, we will check to see if the envFrom sources exist
+	if i == SyncOp {
+		if er := checkEnvFromSources(ctx, c, config); er != nil {
+			return nil, er
+		}
+	}
+
+	if i == DestroyOp {
+		// the deployment goes first, before the objects it depends on
+		for _, obj := range orderObjects(objs, i) {
+			err := c.Delete(ctx, obj)
+			if err != nil {
+				// if the object or its CRD is not found, ignore the error
+				if !errors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+					return append(results, newResourceSyncResult(c, obj, SyncFailed, err)), err
+				}
+				continue
+			}
+			results = append(results, newResourceSyncResult(c, obj, SyncDeleted, nil))
+		}
+		staleResults, er := removeStaleRoles(ctx, c, config, i)
+		results = append(results, staleResults...)
+		if er != nil {
+			return results, er
+		}
+		previousResults, er := removePreviousTargetNamespace(ctx, c, config)
+		return append(results, previousResults...), er
+	}
+
+	// before creation, we will check to see if the secret exists if used as a ref
+	if config.Spec.AI.Secret != nil {
+		secret := &corev1.Secret{}
+		er := c.Get(ctx, types.NamespacedName{Name: config.Spec.AI.Secret.Name,
+			Namespace: GetTargetNamespace(config)}, secret)
+		if er != nil {
+			return nil, err.New("references secret does not exist, cannot create deployment")
+		}
+		// GetDeployment cannot read the secret, the hash is added here
+		secretHash, er := GetSecretHash(ctx, c, corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: config.Spec.AI.Secret.Name},
+			Key:                  config.Spec.AI.Secret.Key,
+		}, GetTargetNamespace(config))
+		if er != nil {
+			return nil, er
+		}
+		for _, obj := range objs {
+			if deployment, ok := obj.(*appsv1.Deployment); ok {
+				annotateSecretHash(deployment, secretHash)
+			}
+		}
+	}
+
+	// the objects besides the deployment do not depend on each other and are
+	// synced in parallel
+	var dependencies, deployments []client.Object
+	for _, obj := range objs {
+		if isWorkload(obj) {
+			deployments = append(deployments, obj)
+		} else {
+			dependencies = append(dependencies, obj)
+		}
+	}
+	operations := make([]SyncOperation, len(dependencies))
+	errs := make([]error, len(dependencies))
+	g, gctx := errgroup.WithContext(ctx)
+	for n, obj := range dependencies {
+		n, obj := n, obj
+		g.Go(func() error {
+			operations[n], errs[n] = syncObject(gctx, c, obj)
+			return errs[n]
+		})
+	}
+	er = g.Wait()
+	for n, obj := range dependencies {
+		results = append(results, newResourceSyncResult(c, obj, operations[n], errs[n]))
+	}
+	if er != nil {
+		return results, er
+	}
+
+	// the deployment is only created once everything it depends on has been synced
+	for _, obj := range deployments {
+		operation, er := syncObject(ctx, c, obj)
+		results = append(results, newResourceSyncResult(c, obj, operation, er))
+		if er != nil {
+			return results, er
+		}
+	}
+
+	staleResults, er := removeStaleRoles(ctx, c, config, i)
+	results = append(results, staleResults...)
+	if er != nil {
+		return results, er
+	}
+
+	previousResults, er := removePreviousTargetNamespace(ctx, c, config)
+	results = append(results, previousResults...)
+	if er != nil {
+		return results, er
+	}
+
+	return results, removeInactiveWorkload(ctx, c, config)
+}
+
+// removePreviousTargetNamespace deletes the objects from the namespace k8sgpt
+// was deployed to before spec.targetNamespace changed, as recorded in the status.
+// Cluster scoped objects and the Roles of the watched namespaces are kept.
+func removePreviousTargetNamespace(ctx context.Context, c client.Client,
+	config v1alpha1.K8sGPT) ([]ResourceSyncResult, error) {
+	previous := config.Status.TargetNamespace
+	if previous == "" || previous == GetTargetNamespace(config) {
+		return nil, nil
+	}
+	old := *config.DeepCopy()
+	old.Spec.TargetNamespace = previous
+	objs, er := GetObjects(old)
+	if er != nil {
+		return nil, er
+	}
+	// either workload may have been left behind in the previous namespace
+	objs = append(objs,
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: DeploymentName, Namespace: previous}},
+		&batchv1.CronJob{ObjectMeta: metav1.ObjectMeta{Name: CronJobName, Namespace: previous}},
+	)
+
+	var results []ResourceSyncResult
+	deleted := map[string]bool{}
+	for _, obj := range orderObjects(objs, DestroyOp) {
+		switch obj.(type) {
+		case *r1.Role, *r1.RoleBinding:
+			continue
+		}
+		key := fmt.Sprintf("%T/%s", obj, obj.GetName())
+		if obj.GetNamespace() != previous || deleted[key] {
+			continue
+		}
+		deleted[key] = true
+		if er := c.Delete(ctx, obj); er != nil {
+			if errors.IsNotFound(er) || meta.IsNoMatchError(er) {
+				continue
+			}
+			return append(results, newResourceSyncResult(c, obj, SyncFailed, er)), er
+		}
+		results = append(results, newResourceSyncResult(c, obj, SyncDeleted, nil))
+	}
+	return results, nil
+}
+
+// removeStaleRoles deletes the Roles and RoleBindings of the namespaces that
+// are no longer watched, or of all namespaces when the instance is destroyed
+func removeStaleRoles(ctx context.Context, c client.Client, config v1alpha1.K8sGPT,
+	i SyncOrDestroy) ([]ResourceSyncResult, error) {
+	watched := map[string]bool{}
+	if i == SyncOp {
+		for _, namespace := range config.Spec.WatchedNamespaces {
+			watched[namespace] = true
+		}
+	}
+	roles := &r1.RoleList{}
+	if er := c.List(ctx, roles, client.MatchingLabels(ownerLabels(config))); er != nil {
+		return nil, er
+	}
+	roleBindings := &r1.RoleBindingList{}
+	if er := c.List(ctx, roleBindings, client.MatchingLabels(ownerLabels(config))); er != nil {
+		return nil, er
+	}
+	var stale []client.Object
+	for n := range roleBindings.Items {
+		if !watched[roleBindings.Items[n].Namespace] {
+			stale = append(stale, &roleBindings.Items[n])
+		}
+	}
+	for n := range roles.Items {
+		if !watched[roles.Items[n].Namespace] {
+			stale = append(stale, &roles.Items[n])
+		}
+	}
+
+	var results []ResourceSyncResult
+	for _, obj := range stale {
+		if er := c.Delete(ctx, obj); er != nil && !errors.IsNotFound(er) {
+			return append(results, newResourceSyncResult(c, obj, SyncFailed, er)), er
+		}
+		results = append(results, newResourceSyncResult(c, obj, SyncDeleted, nil))
+	}
+	return results, nil
+}
+
+// mountLocalModel mounts the model file of the AI backend into the k8sgpt container
+func mountLocalModel(config v1alpha1.K8sGPT, deployment *appsv1.Deployment) {
+	modelPath := config.Spec.AI.LocalModelPath
+	volume := corev1.Volume{Name: "k8sgpt-model"}
+	mount := corev1.VolumeMount{Name: "k8sgpt-model", MountPath: modelPath, ReadOnly: true}
+	if config.Spec.AI.LocalModelClaimName != "" {
+		volume.VolumeSource = corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: config.Spec.AI.LocalModelClaimName,
+				ReadOnly:  true,
+			},
+		}
+		mount.MountPath = path.Dir(modelPath)
+	} else {
+		hostPathType := corev1.HostPathFile
+		volume.VolumeSource = corev1.VolumeSource{
+			HostPath: &corev1.HostPathVolumeSource{Path: modelPath, Type: &hostPathType},
+		}
+	}
+	deployment.Spec.Template.Spec.Volumes = append(deployment.Spec.Template.Spec.Volumes, volume)
+	deployment.Spec.Template.Spec.Containers[0].VolumeMounts = append(
+		deployment.Spec.Template.Spec.Containers[0].VolumeMounts, mount,
+	)
+	deployment.Spec.Template.Spec.Containers[0].Env = append(
+		deployment.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{
+			Name:  "K8SGPT_LOCAL_MODEL_PATH",
+			Value: modelPath,
+		},
+	)
+}
+
+// GetSecretHash returns the hex encoded SHA256 hash of the value of the key of the secret
+func GetSecretHash(ctx context.Context, c client.Client, ref corev1.SecretKeySelector, ns string) (string, error) {
+	secret := &corev1.Secret{}
+	if er := c.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: ns}, secret); er != nil {
+		return "", er
+	}
+	value, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", ref.Name, ref.Key)
+	}
+	hash := sha256.Sum256(value)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// annotateSecretHash restarts the k8sgpt pods after the AI secret has been
+// rotated, k8sgpt reads it on start only
+func annotateSecretHash(deployment *appsv1.Deployment, secretHash string) {
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	deployment.Spec.Template.Annotations[SecretHashAnnotation] = secretHash
+}
+
+// syncObject creates or updates the object, it is skipped if it belongs to
+// an optional integration whose CRD is not installed
+func syncObject(ctx context.Context, c client.Client, obj client.Object) (SyncOperation, error) {
+	result, er := doSync(ctx, c, obj)
+	if er != nil {
+		// The CRD of an optional integration (e.g. Prometheus Operator) is not installed
+		if meta.IsNoMatchError(er) {
+			fmt.Printf("Skipping %s %s, its CRD is not installed\n",
+				obj.GetObjectKind().GroupVersionKind().Kind, obj.GetName())
+			return SyncSkipped, nil
+		}
+		// If the object already exists, ignore the error
+		if !errors.IsAlreadyExists(er) {
+			return SyncFailed, er
+		}
+		return SyncUnchanged, nil
+	}
+	switch result {
+	case controllerutil.OperationResultCreated:
+		return SyncCreated, nil
+	case controllerutil.OperationResultNone:
+		return SyncUnchanged, nil
+	default:
+		return SyncUpdated, nil
+	}
+}
+
+// PatchDeployment applies a strategic merge patch to an existing Deployment, for
+// partial updates that should not rebuild the whole object
+func PatchDeployment(ctx context.Context, c client.Client, name, namespace string, patch []byte) error {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+	if er := c.Patch(ctx, deployment, client.RawPatch(types.StrategicMergePatchType, patch)); er != nil {
+		return fmt.Errorf("failed to patch deployment %s/%s: %w", namespace, name, er)
+	}
+	return nil
+}
+
+// orderObjects puts the deployment after the objects it depends on, e.g. its
+// ServiceAccount and RBAC. Objects are destroyed in the reverse order.
+func orderObjects(objs []client.Object, i SyncOrDestroy) []client.Object {
+	ordered := make([]client.Object, 0, len(objs))
+	var deployments []client.Object
+	for _, obj := range objs {
+		if isWorkload(obj) {
+			deployments = append(deployments, obj)
+			continue
+		}
+		ordered = append(ordered, obj)
+	}
+	ordered = append(ordered, deployments...)
+
+	if i == DestroyOp {
+		for l, r := 0, len(ordered)-1; l < r; l, r = l+1, r-1 {
+			ordered[l], ordered[r] = ordered[r], ordered[l]
+		}
+	}
+	return ordered
+}
+
+// checkEnvFromSources returns an error if a ConfigMap or Secret referenced by
+// envFrom does not exist, unless the reference is optional
+func checkEnvFromSources(ctx context.Context, c client.Client, config v1alpha1.K8sGPT) error {
+	for _, source := range config.Spec.EnvFrom {
+		var obj client.Object
+		var name string
+		var optional *bool
+		switch {
+		case source.ConfigMapRef != nil:
+			obj, name, optional = &corev1.ConfigMap{}, source.ConfigMapRef.Name, source.ConfigMapRef.Optional
+		case source.SecretRef != nil:
+			obj, name, optional = &corev1.Secret{}, source.SecretRef.Name, source.SecretRef.Optional
+		default:
+			continue
+		}
+		if optional != nil && *optional {
+			continue
+		}
+		er := c.Get(ctx, types.NamespacedName{Name: name, Namespace: GetTargetNamespace(config)}, obj)
+		if er != nil {
+			return fmt.Errorf("referenced envFrom source %s does not exist, cannot create deployment", name)
+		}
+	}
+
+	return nil
+}
+
+func doSync(ctx context.Context, clt client.Client, obj client.Object) (controllerutil.OperationResult, error) {
+	var mutateFn controllerutil.MutateFn
+	switch expect := obj.(type) {
+	case *appsv1.Deployment:
+		exist := &appsv1.Deployment{}
+		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
+		if err != nil && !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		} else if err == nil {
+			mutateFn = func() error {
+				exist.Spec = expect.Spec
+				return nil
+			}
+			obj = exist
+		}
+	case *batchv1.CronJob:
+		exist := &batchv1.CronJob{}
+		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
+		if err != nil && !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		} else if err == nil {
+			mutateFn = func() error {
+				exist.Spec = expect.Spec
+				return nil
+			}
+			obj = exist
+		}
+	case *corev1.Service:
+		exist := &corev1.Service{}
+		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
+		if err != nil && !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		} else if err == nil {
+			mutateFn = func() error {
+				exist.Spec = expect.Spec
+				if exist.Labels == nil {
+					exist.Labels = map[string]string{}
+				}
+				for k, v := range expect.Labels {
+					exist.Labels[k] = v
+				}
+				return nil
+			}
+			obj = exist
+		}
+	case *corev1.ServiceAccount:
+		exist := &corev1.ServiceAccount{}
+		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
+		if err != nil && !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		} else if err == nil {
+			mutateFn = func() error {
+				// an unset value keeps whatever the ServiceAccount has
+				if expect.AutomountServiceAccountToken != nil {
+					exist.AutomountServiceAccountToken = expect.AutomountServiceAccountToken
+				}
+				// merged, token controllers and webhooks annotate the ServiceAccount too
+				for k, v := range expect.Annotations {
+					metav1.SetMetaDataAnnotation(&exist.ObjectMeta, k, v)
+				}
+				return nil
+			}
+			obj = exist
+		}
+	case *corev1.ResourceQuota:
+		exist := &corev1.ResourceQuota{}
+		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
+		if err != nil && !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		} else if err == nil {
+			mutateFn = func() error {
+				exist.Spec = expect.Spec
+				return nil
+			}
+			obj = exist
+		}
+	case *networkingv1.NetworkPolicy:
+		exist := &networkingv1.NetworkPolicy{}
+		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
 		if err != nil && !errors.IsNotFound(err) {
-			return err
+			return controllerutil.OperationResultNone, err
 		} else if err == nil {
 			mutateFn = func() error {
 				exist.Spec = expect.Spec
@@ -438,9 +1866,83 @@ func doSync(ctx context.Context, clt client.Client, obj client.Object) error {
 			}
 			obj = exist
 		}
+	case *r1.ClusterRole:
+		exist := &r1.ClusterRole{}
+		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
+		if err != nil && !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		} else if err == nil {
+			mutateFn = func() error {
+				exist.Rules = expect.Rules
+				return nil
+			}
+			obj = exist
+		}
+	case *r1.ClusterRoleBinding:
+		exist := &r1.ClusterRoleBinding{}
+		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
+		if err != nil && !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		} else if err == nil {
+			mutateFn = func() error {
+				// the subject follows spec.targetNamespace
+				exist.Subjects = expect.Subjects
+				exist.RoleRef = expect.RoleRef
+				return nil
+			}
+			obj = exist
+		}
+	case *r1.Role:
+		exist := &r1.Role{}
+		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
+		if err != nil && !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		} else if err == nil {
+			mutateFn = func() error {
+				exist.Rules = expect.Rules
+				// roles created before they were labeled are cleaned up too
+				for k, v := range expect.Labels {
+					metav1.SetMetaDataLabel(&exist.ObjectMeta, k, v)
+				}
+				return nil
+			}
+			obj = exist
+		}
+	case *r1.RoleBinding:
+		exist := &r1.RoleBinding{}
+		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
+		if err != nil && !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		} else if err == nil {
+			mutateFn = func() error {
+				// the role ref cannot be changed
+				exist.Subjects = expect.Subjects
+				for k, v := range expect.Labels {
+					metav1.SetMetaDataLabel(&exist.ObjectMeta, k, v)
+				}
+				return nil
+			}
+			obj = exist
+		}
+	case *unstructured.Unstructured:
+		exist := &unstructured.Unstructured{}
+		exist.SetGroupVersionKind(expect.GroupVersionKind())
+		err := clt.Get(context.Background(), client.ObjectKeyFromObject(obj), exist)
+		if err != nil && !errors.IsNotFound(err) {
+			return controllerutil.OperationResultNone, err
+		} else if err == nil {
+			mutateFn = func() error {
+				exist.Object["spec"] = expect.Object["spec"]
+				return nil
+			}
+			obj = exist
+		}
 	}
-	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
-		_, err := controllerutil.CreateOrPatch(ctx, clt, obj, mutateFn)
+	var result controllerutil.OperationResult
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var err error
+		result, err = controllerutil.CreateOrPatch(ctx, clt, obj, mutateFn)
 		return err
 	})
+	return result, err
 }
@@ -0,0 +1,97 @@
+package resources
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	r1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+// Test_SyncLifecycle runs Sync against a real API server, the envtest assets
+// are provided by `make test`
+func Test_SyncLifecycle(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set, run the envtest based tests with make test")
+	}
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := testEnv.Start()
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, testEnv.Stop())
+	}()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	config := &v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+			Version:    "v0.3.8",
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+				Model:   "gpt-3.5-turbo",
+			},
+		},
+	}
+	require.NoError(t, c.Create(ctx, config))
+	// the owner references need the type meta, which is dropped on create
+	config.SetGroupVersionKind(v1alpha1.GroupVersion.WithKind("K8sGPT"))
+
+	objects := []client.Object{
+		&v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt", Namespace: "default"}},
+		&v1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt", Namespace: "default"}},
+		&r1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt"}},
+		&r1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: DeploymentName, Namespace: "default"}},
+	}
+
+	// sync creates every object, owned by the K8sGPT instance
+	require.NoError(t, Sync(ctx, c, *config, SyncOp))
+	for _, obj := range objects {
+		require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(obj), obj))
+		require.Len(t, obj.GetOwnerReferences(), 1)
+		assert.Equal(t, config.UID, obj.GetOwnerReferences()[0].UID)
+	}
+
+	// a second sync is a no-op
+	require.NoError(t, Sync(ctx, c, *config, SyncOp))
+
+	deployments := &appsv1.DeploymentList{}
+	require.NoError(t, c.List(ctx, deployments, client.InNamespace("default")))
+	assert.Len(t, deployments.Items, 1)
+
+	// destroy removes every object again
+	require.NoError(t, Sync(ctx, c, *config, DestroyOp))
+	for _, obj := range objects {
+		err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj)
+		assert.True(t, errors.IsNotFound(err), "%T %s was not deleted", obj, obj.GetName())
+	}
+
+	// destroying twice is not an error
+	require.NoError(t, Sync(ctx, c, *config, DestroyOp))
+}
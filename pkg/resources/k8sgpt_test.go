@@ -2,17 +2,29 @@ package resources
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	nodev1 "k8s.io/api/node/v1"
+	r1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/utils/pointer"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 )
 
 func Test_DeploymentShouldBeSynced(t *testing.T) {
@@ -44,7 +56,7 @@ func Test_DeploymentShouldBeSynced(t *testing.T) {
 	}
 
 	// test
-	err := doSync(ctx, fakeClient, deployment)
+	_, err := doSync(ctx, fakeClient, deployment)
 	require.NoError(t, err)
 
 	existDeployment := &appsv1.Deployment{}
@@ -60,9 +72,10 @@ func Test_DeploymentShouldBeSynced(t *testing.T) {
 	deploymentUpdated := deployment.DeepCopy()
 	updatedImage := "ghcr.io/k8sgpt-ai/k8sgpt:latest"
 	deploymentUpdated.Spec.Template.Spec.Containers[0].Image = updatedImage
+	deploymentUpdated.Spec.MinReadySeconds = 10
 
 	// test
-	err = doSync(ctx, fakeClient, deploymentUpdated)
+	_, err = doSync(ctx, fakeClient, deploymentUpdated)
 	require.NoError(t, err)
 	err = fakeClient.Get(ctx, client.ObjectKeyFromObject(deployment), existDeployment)
 	require.NoError(t, err)
@@ -70,6 +83,7 @@ func Test_DeploymentShouldBeSynced(t *testing.T) {
 	// verify
 	assert.NotNil(t, existDeployment)
 	assert.Equal(t, updatedImage, existDeployment.Spec.Template.Spec.Containers[0].Image)
+	assert.Equal(t, int32(10), existDeployment.Spec.MinReadySeconds)
 }
 
 func Test_ServiceAccountShouldNotBeSynced(t *testing.T) {
@@ -89,7 +103,7 @@ func Test_ServiceAccountShouldNotBeSynced(t *testing.T) {
 	}
 
 	// test
-	err := doSync(ctx, fakeClient, serviceAccount)
+	_, err := doSync(ctx, fakeClient, serviceAccount)
 	require.NoError(t, err)
 
 	existSA := &v1.ServiceAccount{}
@@ -106,7 +120,7 @@ func Test_ServiceAccountShouldNotBeSynced(t *testing.T) {
 	saUpdated.AutomountServiceAccountToken = nil
 
 	// test
-	err = doSync(ctx, fakeClient, saUpdated)
+	_, err = doSync(ctx, fakeClient, saUpdated)
 	require.NoError(t, err)
 	err = fakeClient.Get(ctx, client.ObjectKeyFromObject(saUpdated), existSA)
 	require.NoError(t, err)
@@ -115,3 +129,2357 @@ func Test_ServiceAccountShouldNotBeSynced(t *testing.T) {
 	assert.NotNil(t, existSA)
 	assert.NotNil(t, existSA.AutomountServiceAccountToken)
 }
+
+func Test_GetDeploymentWithRedisRemoteCache(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			RemoteCache: &v1alpha1.RemoteCacheRef{
+				Redis: &v1alpha1.RedisBackend{
+					Address: "redis.default.svc:6379",
+				},
+			},
+		},
+	}
+
+	// without a password
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_CACHE_TYPE", Value: "redis"})
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_REDIS_ADDRESS", Value: "redis.default.svc:6379"})
+	for _, envVar := range env {
+		assert.NotEqual(t, "K8SGPT_REDIS_PASSWORD", envVar.Name)
+	}
+
+	config.Spec.RemoteCache.Redis.Credentials = &v1.LocalObjectReference{Name: "k8sgpt-redis-secret"}
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, v1.EnvVar{
+		Name: "K8SGPT_REDIS_PASSWORD",
+		ValueFrom: &v1.EnvVarSource{
+			SecretKeyRef: &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: "k8sgpt-redis-secret"},
+				Key:                  "redis_password",
+			},
+		},
+	})
+
+	// only one remote cache backend may be set
+	config.Spec.RemoteCache.S3 = &v1alpha1.S3Backend{BucketName: "foo"}
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentWithRollingUpdateParameters(t *testing.T) {
+	maxSurge := intstr.FromInt(2)
+	maxUnavailable := intstr.FromString("50%")
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			MaxSurge:       &maxSurge,
+			MaxUnavailable: &maxUnavailable,
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Equal(t, appsv1.RollingUpdateDeploymentStrategyType, deployment.Spec.Strategy.Type)
+	assert.Equal(t, &maxSurge, deployment.Spec.Strategy.RollingUpdate.MaxSurge)
+	assert.Equal(t, &maxUnavailable, deployment.Spec.Strategy.RollingUpdate.MaxUnavailable)
+
+	// rolling update parameters can't be combined with the Recreate strategy
+	config.Spec.UpdateStrategy.Type = appsv1.RecreateDeploymentStrategyType
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_SyncAnthropicBackend(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "anthropic-secret",
+			Namespace: "default",
+		},
+		Data: map[string][]byte{"api-key": []byte("secret")},
+	}
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.Anthropic,
+				Model:   "claude-3-opus-20240229",
+				Secret: &v1alpha1.SecretRef{
+					Name: "anthropic-secret",
+					Key:  "api-key",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		objects []client.Object
+		wantErr bool
+	}{
+		{
+			name:    "secret exists",
+			objects: []client.Object{secret},
+		},
+		{
+			name:    "secret is missing",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.objects...).Build()
+
+			err := Sync(ctx, fakeClient, config, SyncOp)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			deployment := &appsv1.Deployment{}
+			require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "default"}, deployment))
+			env := deployment.Spec.Template.Spec.Containers[0].Env
+			assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_BACKEND", Value: v1alpha1.Anthropic})
+			assert.Contains(t, env, v1.EnvVar{
+				Name: "K8SGPT_PASSWORD",
+				ValueFrom: &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "anthropic-secret"},
+						Key:                  "api-key",
+					},
+				},
+			})
+		})
+	}
+}
+
+func Test_GetDeploymentGroqBackend(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.Groq,
+				Model:   "llama3-70b-8192",
+				Secret: &v1alpha1.SecretRef{
+					Name: "groq-secret",
+					Key:  "api-key",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		mutate      func(ai *v1alpha1.AISpec)
+		wantBaseUrl string
+		wantErr     bool
+	}{
+		{
+			name:        "default base url",
+			mutate:      func(ai *v1alpha1.AISpec) {},
+			wantBaseUrl: GroqBaseUrl,
+		},
+		{
+			name: "base url override",
+			mutate: func(ai *v1alpha1.AISpec) {
+				ai.BaseUrl = "https://groq.example.com"
+			},
+			wantBaseUrl: "https://groq.example.com",
+		},
+		{
+			name: "secret is missing",
+			mutate: func(ai *v1alpha1.AISpec) {
+				ai.Secret = nil
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := *config.DeepCopy()
+			tt.mutate(config.Spec.AI)
+
+			if tt.wantErr {
+				assert.NotEmpty(t, ValidateConfig(config))
+				return
+			}
+			deployment, err := GetDeployment(config)
+			require.NoError(t, err)
+			env := deployment.Spec.Template.Spec.Containers[0].Env
+			assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_BACKEND", Value: v1alpha1.Groq})
+			assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_BASEURL", Value: tt.wantBaseUrl})
+			assert.Contains(t, env, v1.EnvVar{
+				Name: "K8SGPT_PASSWORD",
+				ValueFrom: &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "groq-secret"},
+						Key:                  "api-key",
+					},
+				},
+			})
+		})
+	}
+}
+
+func Test_GetDeploymentMistralBackend(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.Mistral,
+				Model:   "mistral-large-latest",
+				Secret: &v1alpha1.SecretRef{
+					Name: "mistral-secret",
+					Key:  "api-key",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		mutate      func(ai *v1alpha1.AISpec)
+		wantBaseUrl string
+		wantErr     bool
+	}{
+		{
+			name:        "default base url",
+			mutate:      func(ai *v1alpha1.AISpec) {},
+			wantBaseUrl: MistralBaseUrl,
+		},
+		{
+			name: "base url override",
+			mutate: func(ai *v1alpha1.AISpec) {
+				ai.BaseUrl = "https://mistral.example.com"
+			},
+			wantBaseUrl: "https://mistral.example.com",
+		},
+		{
+			name: "secret is missing",
+			mutate: func(ai *v1alpha1.AISpec) {
+				ai.Secret = nil
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := *config.DeepCopy()
+			tt.mutate(config.Spec.AI)
+
+			if tt.wantErr {
+				assert.NotEmpty(t, ValidateConfig(config))
+				return
+			}
+			deployment, err := GetDeployment(config)
+			require.NoError(t, err)
+			env := deployment.Spec.Template.Spec.Containers[0].Env
+			assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_BACKEND", Value: v1alpha1.Mistral})
+			assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_BASEURL", Value: tt.wantBaseUrl})
+			assert.Contains(t, env, v1.EnvVar{
+				Name: "K8SGPT_PASSWORD",
+				ValueFrom: &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "mistral-secret"},
+						Key:                  "api-key",
+					},
+				},
+			})
+		})
+	}
+}
+
+func Test_GetDeploymentProgressDeadlineSeconds(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			MinReadySeconds:         30,
+			ProgressDeadlineSeconds: pointer.Int32(120),
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Equal(t, pointer.Int32(120), deployment.Spec.ProgressDeadlineSeconds)
+
+	config.Spec.ProgressDeadlineSeconds = pointer.Int32(30)
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_SyncTargetNamespace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			TargetNamespace: "k8sgpt-system",
+		},
+	}
+	namespaces := []client.Object{
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-system"}},
+	}
+
+	// the target namespace is missing
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespaces[0]).Build()
+	require.Error(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	fakeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespaces...).Build()
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "k8sgpt-system"}, deployment))
+	assert.Empty(t, deployment.OwnerReferences)
+
+	clusterRoleBinding, err := GetClusterRoleBinding(config)
+	require.NoError(t, err)
+	assert.Equal(t, "k8sgpt-system", clusterRoleBinding.Subjects[0].Namespace)
+}
+
+func Test_SyncShouldMoveToTheNewTargetNamespace(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			TargetNamespace: "k8sgpt-system",
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-system"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-other"}},
+	).Build()
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	config.Status.TargetNamespace = "k8sgpt-system"
+	config.Spec.TargetNamespace = "k8sgpt-other"
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	// the binding grants the permissions to the new ServiceAccount
+	clusterRoleBinding := &r1.ClusterRoleBinding{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "k8sgpt"}, clusterRoleBinding))
+	require.Len(t, clusterRoleBinding.Subjects, 1)
+	assert.Equal(t, "k8sgpt-other", clusterRoleBinding.Subjects[0].Namespace)
+
+	for _, obj := range []client.Object{&appsv1.Deployment{}, &v1.Service{}, &v1.ServiceAccount{}} {
+		key := client.ObjectKey{Name: DeploymentName, Namespace: "k8sgpt-other"}
+		old := client.ObjectKey{Name: DeploymentName, Namespace: "k8sgpt-system"}
+		if _, ok := obj.(*appsv1.Deployment); !ok {
+			key.Name, old.Name = "k8sgpt", "k8sgpt"
+		}
+		assert.NoError(t, fakeClient.Get(ctx, key, obj), "%T", obj)
+		assert.True(t, errors.IsNotFound(fakeClient.Get(ctx, old, obj)), "%T", obj)
+	}
+}
+
+func Test_SyncWatchedNamespaces(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			WatchedNamespaces: []string{"default", "team-a"},
+		},
+	}
+	namespaces := []client.Object{
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+	}
+
+	// a watched namespace is missing
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespaces[0]).Build()
+	require.Error(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	// created before the roles were labeled
+	unlabeled := []client.Object{
+		&r1.Role{ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt", Namespace: "team-a"}},
+		&r1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt", Namespace: "team-a"},
+			RoleRef:    r1.RoleRef{Kind: "Role", Name: "k8sgpt", APIGroup: "rbac.authorization.k8s.io"},
+		},
+	}
+	fakeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(namespaces...).WithObjects(unlabeled...).Build()
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "default"}, deployment))
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_NAMESPACES", Value: "default,team-a"})
+
+	// the cluster wide access is replaced by the roles
+	clusterRole := &r1.ClusterRole{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "k8sgpt"}, clusterRole))
+	for _, rule := range clusterRole.Rules {
+		assert.NotContains(t, rule.APIGroups, "*", "%v", rule)
+	}
+	for _, namespace := range config.Spec.WatchedNamespaces {
+		role := &r1.Role{}
+		require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "k8sgpt", Namespace: namespace}, role))
+		assert.Equal(t, []string{"*"}, role.Rules[0].Resources)
+		roleBinding := &r1.RoleBinding{}
+		require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "k8sgpt", Namespace: namespace}, roleBinding))
+		assert.Equal(t, "default", roleBinding.Subjects[0].Namespace)
+		// only objects in the namespace of the K8sGPT object can be owned by it
+		assert.Equal(t, namespace == "default", len(role.OwnerReferences) == 1)
+	}
+
+	// the access to a namespace no longer watched is revoked
+	config.Spec.WatchedNamespaces = []string{"default"}
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	key := client.ObjectKey{Name: "k8sgpt", Namespace: "team-a"}
+	assert.True(t, errors.IsNotFound(fakeClient.Get(ctx, key, &r1.Role{})))
+	assert.True(t, errors.IsNotFound(fakeClient.Get(ctx, key, &r1.RoleBinding{})))
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "k8sgpt", Namespace: "default"}, &r1.Role{}))
+
+	// and to all of them with the instance, even if they were unwatched before
+	require.NoError(t, fakeClient.Create(ctx, &r1.Role{ObjectMeta: metav1.ObjectMeta{
+		Name: "k8sgpt", Namespace: "team-a", Labels: ownerLabels(config),
+	}}))
+	require.NoError(t, Sync(ctx, fakeClient, config, DestroyOp))
+	roles := &r1.RoleList{}
+	require.NoError(t, fakeClient.List(ctx, roles))
+	assert.Empty(t, roles.Items)
+	roleBindings := &r1.RoleBindingList{}
+	require.NoError(t, fakeClient.List(ctx, roleBindings))
+	assert.Empty(t, roleBindings.Items)
+}
+
+func Test_GetDeploymentSpecHashLabel(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			Version: "v0.3.8",
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	hash := deployment.Spec.Template.Labels[SpecHashLabel]
+	assert.Len(t, hash, 32)
+
+	// the same spec always results in the same hash
+	deployment, err = GetDeployment(*config.DeepCopy())
+	require.NoError(t, err)
+	assert.Equal(t, hash, deployment.Spec.Template.Labels[SpecHashLabel])
+
+	config.Spec.Version = "v0.3.9"
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, deployment.Spec.Template.Labels[SpecHashLabel])
+}
+
+func Test_SyncEnvFrom(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+
+	configMap := &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-proxy",
+			Namespace: "default",
+		},
+		Data: map[string]string{"HTTPS_PROXY": "http://proxy:3128"},
+	}
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			EnvFrom: []v1.EnvFromSource{
+				{ConfigMapRef: &v1.ConfigMapEnvSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: "k8sgpt-proxy"},
+				}},
+				{SecretRef: &v1.SecretEnvSource{
+					LocalObjectReference: v1.LocalObjectReference{Name: "k8sgpt-flags"},
+					Optional:             pointer.Bool(true),
+				}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		objects []client.Object
+		wantErr bool
+	}{
+		{
+			name:    "configmap exists",
+			objects: []client.Object{configMap},
+		},
+		{
+			name:    "configmap is missing",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.objects...).Build()
+
+			err := Sync(ctx, fakeClient, config, SyncOp)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			deployment := &appsv1.Deployment{}
+			require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "default"}, deployment))
+			assert.Equal(t, config.Spec.EnvFrom, deployment.Spec.Template.Spec.Containers[0].EnvFrom)
+		})
+	}
+}
+
+func Test_SyncRuntimeClassName(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			RuntimeClassName: pointer.String("gvisor"),
+		},
+	}
+
+	// the runtime class is missing
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	require.Error(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	runtimeClass := &nodev1.RuntimeClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "gvisor"},
+		Handler:    "runsc",
+	}
+	fakeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(runtimeClass).Build()
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "default"}, deployment))
+	assert.Equal(t, pointer.String("gvisor"), deployment.Spec.Template.Spec.RuntimeClassName)
+}
+
+func Test_GetDeploymentAzureOpenAIAPIVersion(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend:    v1alpha1.AzureOpenAI,
+				Engine:     "llm",
+				APIVersion: "2023-05-15",
+			},
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_AZURE_API_VERSION", Value: "2023-05-15"})
+
+	// optional, existing azureopenai configs keep the k8sgpt default
+	config.Spec.AI.APIVersion = ""
+	assert.Empty(t, ValidateConfig(config))
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		assert.NotEqual(t, "K8SGPT_AZURE_API_VERSION", env.Name)
+	}
+
+	// not supported by other providers
+	config.Spec.AI = &v1alpha1.AISpec{
+		Backend:    v1alpha1.OpenAI,
+		APIVersion: "2023-05-15",
+	}
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentAzureAD(t *testing.T) {
+	clientSecretRef := &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "k8sgpt-azure-ad"},
+		Key:                  "client-secret",
+	}
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend:    v1alpha1.AzureOpenAI,
+				APIVersion: "2023-05-15",
+				AzureAD: &v1alpha1.AzureADSpec{
+					TenantID:        "tenant",
+					ClientID:        "client",
+					ClientSecretRef: clientSecretRef,
+				},
+			},
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	assert.Contains(t, env, v1.EnvVar{Name: "AZURE_TENANT_ID", Value: "tenant"})
+	assert.Contains(t, env, v1.EnvVar{Name: "AZURE_CLIENT_ID", Value: "client"})
+	assert.Contains(t, env, v1.EnvVar{
+		Name:      "AZURE_CLIENT_SECRET",
+		ValueFrom: &v1.EnvVarSource{SecretKeyRef: clientSecretRef},
+	})
+
+	// a managed identity has no client secret
+	config.Spec.AI.AzureAD.ClientSecretRef = nil
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		assert.NotEqual(t, "AZURE_CLIENT_SECRET", env.Name)
+	}
+
+	config.Spec.AI.Secret = &v1alpha1.SecretRef{Name: "k8sgpt-secret", Key: "azure-api-key"}
+	assert.NotEmpty(t, ValidateConfig(config))
+
+	config.Spec.AI.Secret = nil
+	config.Spec.AI.AzureAD.TenantID = ""
+	assert.NotEmpty(t, ValidateConfig(config))
+
+	// not supported by other providers
+	config.Spec.AI = &v1alpha1.AISpec{
+		Backend: v1alpha1.OpenAI,
+		AzureAD: &v1alpha1.AzureADSpec{TenantID: "tenant", ClientID: "client"},
+	}
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentCacheResults(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+		},
+	}
+	cache := v1.EnvVar{Name: "K8SGPT_CACHE", Value: "true"}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.NotContains(t, deployment.Spec.Template.Spec.Containers[0].Env, cache)
+
+	config.Spec.AI.CacheResults = true
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, cache)
+
+	// fresh results are the opposite of cached ones
+	config.Spec.NoCache = true
+	assert.NotEmpty(t, ValidateConfig(config))
+
+	config.Spec.AI.CacheResults = false
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, v1.EnvVar{Name: "K8SGPT_NO_CACHE", Value: "true"})
+}
+
+func Test_SyncRevisionHistoryLimit(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.Background()
+
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			RevisionHistoryLimit: pointer.Int32(3),
+		},
+	}
+	key := client.ObjectKey{Name: DeploymentName, Namespace: "default"}
+
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	assert.Equal(t, pointer.Int32(3), deployment.Spec.RevisionHistoryLimit)
+
+	// the limit is updated on existing deployments
+	config.Spec.RevisionHistoryLimit = pointer.Int32(1)
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	assert.Equal(t, pointer.Int32(1), deployment.Spec.RevisionHistoryLimit)
+
+	config.Spec.RevisionHistoryLimit = pointer.Int32(-1)
+	assert.Error(t, Sync(ctx, fakeClient, config, SyncOp))
+}
+
+func Test_SyncAutomountServiceAccountToken(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.Background()
+
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			AutomountServiceAccountToken: pointer.Bool(true),
+		},
+	}
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	// disable the token on an existing ServiceAccount and Deployment
+	config.Spec.AutomountServiceAccountToken = pointer.Bool(false)
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	serviceAccount := &v1.ServiceAccount{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: "k8sgpt", Namespace: "default"}, serviceAccount))
+	assert.Equal(t, pointer.Bool(false), serviceAccount.AutomountServiceAccountToken)
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "default"}, deployment))
+	assert.Equal(t, pointer.Bool(false), deployment.Spec.Template.Spec.AutomountServiceAccountToken)
+}
+
+func Test_SyncServiceAccountAnnotations(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.Background()
+
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			ServiceAccountAnnotations: map[string]string{
+				"eks.amazonaws.com/role-arn": "arn:aws:iam::111122223333:role/k8sgpt",
+			},
+		},
+	}
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	// an annotation added by someone else, e.g. an injector
+	serviceAccount := &v1.ServiceAccount{}
+	key := client.ObjectKey{Name: "k8sgpt", Namespace: "default"}
+	require.NoError(t, fakeClient.Get(ctx, key, serviceAccount))
+	serviceAccount.Annotations["injector.example.com/status"] = "injected"
+	require.NoError(t, fakeClient.Update(ctx, serviceAccount))
+
+	config.Spec.ServiceAccountAnnotations["eks.amazonaws.com/role-arn"] = "arn:aws:iam::111122223333:role/k8sgpt-v2"
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	require.NoError(t, fakeClient.Get(ctx, key, serviceAccount))
+	assert.Equal(t, map[string]string{
+		"eks.amazonaws.com/role-arn":  "arn:aws:iam::111122223333:role/k8sgpt-v2",
+		"injector.example.com/status": "injected",
+	}, serviceAccount.Annotations)
+}
+
+func Test_GetDeploymentModelOverridePerAnalyzer(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+				Model:   "gpt-3.5-turbo",
+				ModelOverridePerAnalyzer: map[string]string{
+					"Pod": "gpt-4o-mini",
+					"Log": "gpt-4o",
+				},
+			},
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_MODEL_OVERRIDE_LOG", Value: "gpt-4o"})
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_MODEL_OVERRIDE_POD", Value: "gpt-4o-mini"})
+
+	config.Spec.AI.ModelOverridePerAnalyzer["Unknown"] = "gpt-4o"
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentExtraHeaders(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+				ExtraHeaders: map[string]string{
+					"X-Organization-ID": "org",
+					"X-Team":            "platform",
+				},
+				ExtraHeadersSecretRef: &v1.SecretReference{Name: "k8sgpt-headers"},
+			},
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	container := deployment.Spec.Template.Spec.Containers[0]
+	assert.Contains(t, container.Env, v1.EnvVar{Name: "K8SGPT_EXTRA_HEADER_X_ORGANIZATION_ID", Value: "org"})
+	assert.Contains(t, container.Env, v1.EnvVar{Name: "K8SGPT_EXTRA_HEADER_X_TEAM", Value: "platform"})
+	assert.Empty(t, container.EnvFrom)
+
+	// environment variables cannot reference secrets of other namespaces
+	config.Spec.AI.ExtraHeadersSecretRef.Namespace = "kube-system"
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_SyncExtraHeadersSecret(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-headers", Namespace: "default"},
+		Data: map[string][]byte{
+			"X-API-Key":      []byte("key"),
+			"x-gateway-auth": []byte("token"),
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+	ctx := context.Background()
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.ExtraHeadersSecretRef = &v1.SecretReference{Name: "k8sgpt-headers"}
+	})
+
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "default"}, deployment))
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	// the header names are mapped like the ones of spec.ai.extraHeaders
+	for header, name := range map[string]string{
+		"X-API-Key":      "K8SGPT_EXTRA_HEADER_X_API_KEY",
+		"x-gateway-auth": "K8SGPT_EXTRA_HEADER_X_GATEWAY_AUTH",
+	} {
+		assert.Contains(t, env, v1.EnvVar{
+			Name: name,
+			ValueFrom: &v1.EnvVarSource{
+				SecretKeyRef: &v1.SecretKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: "k8sgpt-headers"},
+					Key:                  header,
+				},
+			},
+		})
+	}
+}
+
+func Test_SyncRemoteCacheEncryptionKey(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+
+	encryptionKey := &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "k8sgpt-cache-encryption"},
+		Key:                  "key",
+	}
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			RemoteCache: &v1alpha1.RemoteCacheRef{
+				Redis: &v1alpha1.RedisBackend{
+					Address: "redis:6379",
+				},
+				EncryptionKey: encryptionKey,
+			},
+		},
+	}
+
+	// the secret is missing
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	require.Error(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-cache-encryption", Namespace: "default"},
+		Data:       map[string][]byte{"key": []byte("secret")},
+	}
+	fakeClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "default"}, deployment))
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, v1.EnvVar{
+		Name:      "K8SGPT_CACHE_ENCRYPTION_KEY",
+		ValueFrom: &v1.EnvVarSource{SecretKeyRef: encryptionKey},
+	})
+}
+
+func Test_GetDeploymentContextWindow(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+		},
+	}
+
+	// unset by default
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		assert.NotEqual(t, "K8SGPT_CONTEXT_WINDOW", env.Name)
+	}
+
+	config.Spec.AI.ContextWindow = 8000
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_CONTEXT_WINDOW", Value: "8000"})
+
+	config.Spec.AI.ContextWindow = 500
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentBatchSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		batchSize int32
+		wantEnv   string
+		wantErr   bool
+	}{
+		{name: "unset", batchSize: 0},
+		{name: "minimum", batchSize: 1, wantEnv: "1"},
+		{name: "maximum", batchSize: 100, wantEnv: "100"},
+		{name: "below minimum", batchSize: -1, wantErr: true},
+		{name: "above maximum", batchSize: 101, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.AI.BatchSize = tt.batchSize
+			})
+
+			if tt.wantErr {
+				assert.NotEmpty(t, ValidateConfig(config))
+				return
+			}
+			deployment, err := GetDeployment(config)
+			require.NoError(t, err)
+			var batchSize *v1.EnvVar
+			for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "K8SGPT_BATCH_SIZE" {
+					batchSize = env.DeepCopy()
+				}
+			}
+			if tt.wantEnv == "" {
+				assert.Nil(t, batchSize)
+				return
+			}
+			require.NotNil(t, batchSize)
+			assert.Equal(t, tt.wantEnv, batchSize.Value)
+		})
+	}
+}
+
+func Test_GetDeploymentReadinessGates(t *testing.T) {
+	readinessGates := []v1.PodReadinessGate{
+		{ConditionType: "target-health.elbv2.k8s.aws/k8sgpt"},
+	}
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			ReadinessGates: readinessGates,
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Equal(t, readinessGates, deployment.Spec.Template.Spec.ReadinessGates)
+}
+
+func Test_GetDeploymentBackendFallback(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend:         v1alpha1.OpenAI,
+				BackendFallback: []v1alpha1.AIBackend{v1alpha1.Anthropic, v1alpha1.LocalAI},
+				Secret: &v1alpha1.SecretRef{
+					Name: "k8sgpt-secret",
+					Key:  "api-key",
+				},
+			},
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_BACKEND_FALLBACK", Value: "anthropic,localai"})
+
+	// the backend is repeated
+	config.Spec.AI.BackendFallback = []v1alpha1.AIBackend{v1alpha1.OpenAI}
+	assert.NotEmpty(t, ValidateConfig(config))
+
+	// anthropic needs credentials
+	config.Spec.AI = &v1alpha1.AISpec{
+		Backend:         v1alpha1.LocalAI,
+		BackendFallback: []v1alpha1.AIBackend{v1alpha1.Anthropic},
+	}
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentBaseUrlSecretRef(t *testing.T) {
+	baseUrlSecretRef := &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "k8sgpt-localai"},
+		Key:                  "base-url",
+	}
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend:          v1alpha1.LocalAI,
+				BaseUrlSecretRef: baseUrlSecretRef,
+			},
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, v1.EnvVar{
+		Name:      "K8SGPT_BASEURL",
+		ValueFrom: &v1.EnvVarSource{SecretKeyRef: baseUrlSecretRef},
+	})
+
+	config.Spec.AI.BaseUrl = "http://local-ai.local-ai.svc.cluster.local:8080/v1"
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentS3Endpoint(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			RemoteCache: &v1alpha1.RemoteCacheRef{
+				Credentials: &v1alpha1.CredentialsRef{
+					Name: "k8sgpt-sample-cache-secret",
+				},
+				S3: &v1alpha1.S3Backend{
+					BucketName:         "k8sgpt",
+					Endpoint:           "https://minio.minio.svc:9000",
+					InsecureSkipVerify: true,
+				},
+			},
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_S3_ENDPOINT", Value: "https://minio.minio.svc:9000"})
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_S3_INSECURE_SKIP_VERIFY", Value: "true"})
+
+	config.Spec.RemoteCache.S3.Endpoint = "minio.minio.svc:9000"
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentS3Bucket(t *testing.T) {
+	tests := []struct {
+		name       string
+		bucketName string
+		wantErr    bool
+	}{
+		{name: "valid", bucketName: "k8sgpt-results-01"},
+		{name: "shortest", bucketName: "k8s"},
+		{name: "longest", bucketName: strings.Repeat("k", 63)},
+		{name: "empty", bucketName: "", wantErr: true},
+		{name: "too short", bucketName: "k8", wantErr: true},
+		{name: "too long", bucketName: strings.Repeat("k", 64), wantErr: true},
+		{name: "uppercase", bucketName: "K8sGPT", wantErr: true},
+		{name: "dots", bucketName: "k8sgpt.results", wantErr: true},
+		{name: "leading hyphen", bucketName: "-k8sgpt", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.RemoteCache = &v1alpha1.RemoteCacheRef{
+					Credentials: &v1alpha1.CredentialsRef{Name: "k8sgpt-cache"},
+					S3: &v1alpha1.S3Backend{
+						BucketName: tt.bucketName,
+						Prefix:     "results/",
+					},
+				}
+			})
+
+			if tt.wantErr {
+				assert.NotEmpty(t, ValidateConfig(config))
+				return
+			}
+			deployment, err := GetDeployment(config)
+			require.NoError(t, err)
+			assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+				v1.EnvVar{Name: "K8SGPT_S3_PREFIX", Value: "results/"})
+		})
+	}
+}
+
+func Test_GetDeploymentRetryPolicy(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+				RetryPolicy: &v1alpha1.AIRetryPolicy{
+					MaxRetries: 5,
+					RetryDelay: &metav1.Duration{Duration: 2 * time.Second},
+				},
+			},
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_MAX_RETRIES", Value: "5"})
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_RETRY_DELAY", Value: "2s"})
+
+	config.Spec.AI.RetryPolicy.RetryDelay = &metav1.Duration{}
+	assert.NotEmpty(t, ValidateConfig(config))
+
+	config.Spec.AI.RetryPolicy = &v1alpha1.AIRetryPolicy{MaxRetries: -1}
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentPromptTemplate(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend:        v1alpha1.OpenAI,
+				PromptTemplate: "Explain why {{.ResourceName}} is \"failing\"\nin simple terms",
+			},
+		},
+	}
+
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, v1.EnvVar{
+		Name:  "K8SGPT_PROMPT_TEMPLATE",
+		Value: "Explain+why+%7B%7B.ResourceName%7D%7D+is+%22failing%22%0Ain+simple+terms",
+	})
+
+	config.Spec.AI.PromptTemplate = "Explain why the resource is failing"
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func newTestConfig(mutate func(*v1alpha1.K8sGPT)) v1alpha1.K8sGPT {
+	config := v1alpha1.K8sGPT{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "K8sGPT",
+			APIVersion: v1alpha1.GroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+			UID:       "6f0c4a3e",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			Repository: "ghcr.io/k8sgpt-ai/k8sgpt",
+			Version:    "v0.3.8",
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+				Model:   "gpt-3.5-turbo",
+			},
+		},
+	}
+	if mutate != nil {
+		mutate(&config)
+	}
+	return config
+}
+
+func Test_GetService(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        v1alpha1.K8sGPT
+		wantNamespace string
+		wantPorts     []string
+	}{
+		{
+			name:          "default",
+			config:        newTestConfig(nil),
+			wantNamespace: "default",
+			wantPorts:     []string{""},
+		},
+		{
+			name: "target namespace",
+			config: newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.TargetNamespace = "k8sgpt-system"
+			}),
+			wantNamespace: "k8sgpt-system",
+			wantPorts:     []string{""},
+		},
+		{
+			name: "metrics",
+			config: newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.Monitoring = &v1alpha1.MonitoringSpec{Enabled: true}
+			}),
+			wantNamespace: "default",
+			wantPorts:     []string{"grpc", MetricsPortName},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, err := GetService(tt.config)
+			require.NoError(t, err)
+			assert.Equal(t, "k8sgpt", service.Name)
+			assert.Equal(t, tt.wantNamespace, service.Namespace)
+			assert.Equal(t, map[string]string{"app": DeploymentName}, service.Spec.Selector)
+			assert.Equal(t, tt.config.UID, service.OwnerReferences[0].UID)
+			var ports []string
+			for _, port := range service.Spec.Ports {
+				ports = append(ports, port.Name)
+			}
+			assert.Equal(t, tt.wantPorts, ports)
+		})
+	}
+}
+
+func Test_GetServiceAccount(t *testing.T) {
+	tests := []struct {
+		name            string
+		config          v1alpha1.K8sGPT
+		wantAutomount   *bool
+		wantAnnotations map[string]string
+	}{
+		{
+			name:   "default",
+			config: newTestConfig(nil),
+		},
+		{
+			name: "workload identity",
+			config: newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.AutomountServiceAccountToken = pointer.Bool(false)
+				c.Spec.ServiceAccountAnnotations = map[string]string{"azure.workload.identity/client-id": "client"}
+			}),
+			wantAutomount:   pointer.Bool(false),
+			wantAnnotations: map[string]string{"azure.workload.identity/client-id": "client"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serviceAccount, err := GetServiceAccount(tt.config)
+			require.NoError(t, err)
+			assert.Equal(t, "k8sgpt", serviceAccount.Name)
+			assert.Equal(t, "default", serviceAccount.Namespace)
+			assert.Equal(t, tt.wantAutomount, serviceAccount.AutomountServiceAccountToken)
+			assert.Equal(t, tt.wantAnnotations, serviceAccount.Annotations)
+		})
+	}
+}
+
+func Test_GetClusterRole(t *testing.T) {
+	extraRule := r1.PolicyRule{
+		APIGroups: []string{""},
+		Resources: []string{"secrets"},
+		Verbs:     []string{"*"},
+	}
+	tests := []struct {
+		name          string
+		config        v1alpha1.K8sGPT
+		wantRules     int
+		wantAnalysis  bool
+		wantSensitive int
+	}{
+		{
+			name:         "default",
+			config:       newTestConfig(nil),
+			wantRules:    3,
+			wantAnalysis: true,
+		},
+		{
+			name: "watched namespaces",
+			config: newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.WatchedNamespaces = []string{"team-a"}
+			}),
+			wantRules: 3,
+		},
+		{
+			name: "extra rules",
+			config: newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.ExtraClusterRoleRules = []r1.PolicyRule{
+					extraRule,
+					{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+				}
+			}),
+			wantRules:     5,
+			wantAnalysis:  true,
+			wantSensitive: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterRole, err := GetClusterRole(tt.config)
+			require.NoError(t, err)
+			assert.Equal(t, "k8sgpt", clusterRole.Name)
+			assert.Len(t, clusterRole.Rules, tt.wantRules)
+			assert.Equal(t, tt.wantAnalysis, clusterRole.Rules[0].APIGroups[0] == "*")
+			assert.Contains(t, clusterRole.Rules, r1.PolicyRule{
+				APIGroups: []string{"core.k8sgpt.ai"},
+				Resources: []string{"results"},
+				Verbs:     []string{"get", "list", "watch", "create", "update", "patch"},
+			})
+			assert.Len(t, GetSensitiveClusterRoleRules(tt.config), tt.wantSensitive)
+		})
+	}
+}
+
+func Test_GetClusterRoleBinding(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        v1alpha1.K8sGPT
+		wantNamespace string
+	}{
+		{
+			name:          "default",
+			config:        newTestConfig(nil),
+			wantNamespace: "default",
+		},
+		{
+			name: "target namespace",
+			config: newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.TargetNamespace = "k8sgpt-system"
+			}),
+			wantNamespace: "k8sgpt-system",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusterRoleBinding, err := GetClusterRoleBinding(tt.config)
+			require.NoError(t, err)
+			assert.Equal(t, r1.RoleRef{Kind: "ClusterRole", Name: "k8sgpt", APIGroup: "rbac.authorization.k8s.io"},
+				clusterRoleBinding.RoleRef)
+			assert.Equal(t, []r1.Subject{{Kind: "ServiceAccount", Name: "k8sgpt", Namespace: tt.wantNamespace}},
+				clusterRoleBinding.Subjects)
+		})
+	}
+}
+
+func Test_GetDeploymentTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  v1alpha1.K8sGPT
+		wantEnv []v1.EnvVar
+		wantErr bool
+	}{
+		{
+			name:   "default",
+			config: newTestConfig(nil),
+			wantEnv: []v1.EnvVar{
+				{Name: "K8SGPT_MODEL", Value: "gpt-3.5-turbo"},
+				{Name: "K8SGPT_BACKEND", Value: v1alpha1.OpenAI},
+			},
+		},
+		{
+			name: "optional ai fields",
+			config: newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.AI.Language = "german"
+				c.Spec.AI.BaseUrl = "https://openai.example.com/v1"
+				c.Spec.AI.ReasoningEffort = "low"
+			}),
+			wantEnv: []v1.EnvVar{
+				{Name: "K8SGPT_LANGUAGE", Value: "german"},
+				{Name: "K8SGPT_BASEURL", Value: "https://openai.example.com/v1"},
+				{Name: "K8SGPT_REASONING_EFFORT", Value: "low"},
+			},
+		},
+		{
+			name: "azure remote cache",
+			config: newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.RemoteCache = &v1alpha1.RemoteCacheRef{
+					Credentials: &v1alpha1.CredentialsRef{Name: "k8sgpt-cache"},
+					Azure:       &v1alpha1.AzureBackend{StorageAccount: "account", ContainerName: "container"},
+				}
+			}),
+			wantEnv: []v1.EnvVar{
+				{
+					Name: "AZURE_CLIENT_ID",
+					ValueFrom: &v1.EnvVarSource{SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "k8sgpt-cache"},
+						Key:                  "azure_client_id",
+					}},
+				},
+			},
+		},
+		{
+			name: "azure remote cache without container",
+			config: newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.RemoteCache = &v1alpha1.RemoteCacheRef{
+					Credentials: &v1alpha1.CredentialsRef{Name: "k8sgpt-cache"},
+					Azure:       &v1alpha1.AzureBackend{StorageAccount: "account"},
+				}
+			}),
+			wantErr: true,
+		},
+		{
+			name: "engine without azureopenai",
+			config: newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.AI.Engine = "llm"
+			}),
+			wantErr: true,
+		},
+		{
+			name: "azure ad with the azure remote cache",
+			config: newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.AI.Backend = v1alpha1.AzureOpenAI
+				c.Spec.AI.APIVersion = "2023-05-15"
+				c.Spec.AI.AzureAD = &v1alpha1.AzureADSpec{TenantID: "tenant", ClientID: "client"}
+				c.Spec.RemoteCache = &v1alpha1.RemoteCacheRef{
+					Credentials: &v1alpha1.CredentialsRef{Name: "k8sgpt-cache"},
+					Azure:       &v1alpha1.AzureBackend{StorageAccount: "account", ContainerName: "container"},
+				}
+			}),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantErr {
+				assert.NotEmpty(t, ValidateConfig(tt.config))
+				return
+			}
+			deployment, err := GetDeployment(tt.config)
+			require.NoError(t, err)
+			container := deployment.Spec.Template.Spec.Containers[0]
+			assert.Equal(t, "ghcr.io/k8sgpt-ai/k8sgpt:v0.3.8", container.Image)
+			assert.Equal(t, "k8sgpt", deployment.Spec.Template.Spec.ServiceAccountName)
+			for _, env := range tt.wantEnv {
+				assert.Contains(t, container.Env, env)
+			}
+		})
+	}
+}
+
+func Test_GetDeploymentHostNetwork(t *testing.T) {
+	deployment, err := GetDeployment(newTestConfig(nil))
+	require.NoError(t, err)
+	assert.False(t, deployment.Spec.Template.Spec.HostNetwork)
+	assert.Empty(t, deployment.Spec.Template.Spec.DNSPolicy)
+
+	deployment, err = GetDeployment(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.HostNetwork = true
+	}))
+	require.NoError(t, err)
+	assert.True(t, deployment.Spec.Template.Spec.HostNetwork)
+	assert.Equal(t, v1.DNSClusterFirstWithHostNet, deployment.Spec.Template.Spec.DNSPolicy)
+
+	// the defaulted policy is replaced, an explicit one is kept
+	deployment, err = GetDeployment(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.HostNetwork = true
+		c.Spec.DNSPolicy = v1.DNSClusterFirst
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, v1.DNSClusterFirstWithHostNet, deployment.Spec.Template.Spec.DNSPolicy)
+	deployment, err = GetDeployment(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.HostNetwork = true
+		c.Spec.DNSPolicy = v1.DNSDefault
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, v1.DNSDefault, deployment.Spec.Template.Spec.DNSPolicy)
+}
+
+func Test_ComputeOwnerReference(t *testing.T) {
+	config := newTestConfig(nil)
+
+	ownerReference := ComputeOwnerReference(config)
+	assert.Equal(t, "K8sGPT", ownerReference.Kind)
+	assert.Equal(t, v1alpha1.GroupVersion.String(), ownerReference.APIVersion)
+	assert.Equal(t, config.Name, ownerReference.Name)
+	assert.Equal(t, config.UID, ownerReference.UID)
+	require.NotNil(t, ownerReference.BlockOwnerDeletion)
+	assert.True(t, *ownerReference.BlockOwnerDeletion)
+	require.NotNil(t, ownerReference.Controller)
+	assert.True(t, *ownerReference.Controller)
+}
+
+func Test_GetDeploymentWorkingDir(t *testing.T) {
+	deployment, err := GetDeployment(newTestConfig(nil))
+	require.NoError(t, err)
+	assert.Empty(t, deployment.Spec.Template.Spec.Containers[0].WorkingDir)
+
+	deployment, err = GetDeployment(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.WorkingDir = "/opt/k8sgpt"
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "/opt/k8sgpt", deployment.Spec.Template.Spec.Containers[0].WorkingDir)
+
+	assert.NotEmpty(t, ValidateConfig(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.WorkingDir = "opt/k8sgpt"
+	})))
+}
+
+func Test_SyncShouldApplyObjectsInDependencyOrder(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+	config := newTestConfig(nil)
+
+	// the dependencies of the deployment are created concurrently
+	var mu sync.Mutex
+	var created, deleted []string
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			mu.Lock()
+			created = append(created, fmt.Sprintf("%T", obj))
+			mu.Unlock()
+			return c.Create(ctx, obj, opts...)
+		},
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			deleted = append(deleted, fmt.Sprintf("%T", obj))
+			return c.Delete(ctx, obj, opts...)
+		},
+	}).Build()
+
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	require.NotEmpty(t, created)
+	assert.Equal(t, "*v1.Deployment", created[len(created)-1])
+
+	require.NoError(t, Sync(ctx, fakeClient, config, DestroyOp))
+	require.NotEmpty(t, deleted)
+	assert.Equal(t, "*v1.Deployment", deleted[0])
+}
+
+func Test_SyncShouldDestroyAnInvalidConfig(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.Background()
+	config := newTestConfig(nil)
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+
+	// invalid under a check added after the objects were created
+	config.Spec.WatchedNamespaces = []string{"Not_A_Namespace"}
+	assert.Error(t, Sync(ctx, fakeClient, config, SyncOp))
+	require.NoError(t, Sync(ctx, fakeClient, config, DestroyOp))
+
+	deployment := &appsv1.Deployment{}
+	err := fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "default"}, deployment)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func Test_SyncShouldNotCreateDeploymentWithoutDependencies(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+	config := newTestConfig(nil)
+
+	// the ClusterRole cannot be created
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*r1.ClusterRole); ok {
+				return errors.NewServiceUnavailable("clusterroles are unavailable")
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}).Build()
+
+	err := Sync(ctx, fakeClient, config, SyncOp)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "clusterroles")
+
+	deployment := &appsv1.Deployment{}
+	err = fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "default"}, deployment)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func Test_GetServiceSessionAffinity(t *testing.T) {
+	service, err := GetService(newTestConfig(nil))
+	require.NoError(t, err)
+	assert.Equal(t, v1.ServiceAffinityNone, service.Spec.SessionAffinity)
+	assert.Nil(t, service.Spec.SessionAffinityConfig)
+
+	timeout := int32(600)
+	service, err = GetService(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.SessionAffinity = v1.ServiceAffinityClientIP
+		c.Spec.SessionAffinityConfig = &v1.SessionAffinityConfig{
+			ClientIP: &v1.ClientIPConfig{TimeoutSeconds: &timeout},
+		}
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, v1.ServiceAffinityClientIP, service.Spec.SessionAffinity)
+	assert.Equal(t, int32(600), *service.Spec.SessionAffinityConfig.ClientIP.TimeoutSeconds)
+
+	assert.NotEmpty(t, ValidateConfig(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.SessionAffinityConfig = &v1.SessionAffinityConfig{
+			ClientIP: &v1.ClientIPConfig{TimeoutSeconds: &timeout},
+		}
+	})))
+}
+
+func Test_PatchDeployment(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+
+	deployment, err := GetDeployment(newTestConfig(nil))
+	require.NoError(t, err)
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(deployment).Build()
+
+	patch := []byte(`{"spec":{"template":{"spec":{"containers":[{"name":"k8sgpt","env":[{"name":"K8SGPT_MODEL","value":"gpt-4"}]}]}}}}`)
+	require.NoError(t, PatchDeployment(ctx, fakeClient, DeploymentName, "default", patch))
+
+	patched := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "default"}, patched))
+	container := patched.Spec.Template.Spec.Containers[0]
+	// the env list is merged by name, the other variables are kept
+	assert.Contains(t, container.Env, v1.EnvVar{Name: "K8SGPT_MODEL", Value: "gpt-4"})
+	assert.Len(t, container.Env, len(deployment.Spec.Template.Spec.Containers[0].Env))
+	assert.Equal(t, deployment.Spec.Template.Spec.Containers[0].Image, container.Image)
+
+	err = PatchDeployment(ctx, fakeClient, "missing", "default", patch)
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func Test_GetDeploymentFunctionCalling(t *testing.T) {
+	deployment, err := GetDeployment(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.FunctionCalling = true
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_FUNCTION_CALLING", Value: "true"})
+
+	errs := ValidateConfig(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.Backend = v1alpha1.LocalAI
+		c.Spec.AI.FunctionCalling = true
+	}))
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "openai, azureopenai, anthropic, groq")
+}
+
+func Test_GetDeploymentPodLabels(t *testing.T) {
+	deployment, err := GetDeployment(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.PodLabels = map[string]string{
+			"team": "sre",
+			"app":  "other",
+		}
+	}))
+	require.NoError(t, err)
+	labels := deployment.Spec.Template.Labels
+	assert.Equal(t, "sre", labels["team"])
+	assert.Equal(t, DeploymentName, labels["app"])
+	assert.NotContains(t, deployment.Labels, "team")
+	assert.Equal(t, map[string]string{"app": DeploymentName}, deployment.Spec.Selector.MatchLabels)
+}
+
+func Test_GetDeploymentDNS(t *testing.T) {
+	dnsConfig := &v1.PodDNSConfig{
+		Nameservers: []string{"10.0.0.53"},
+		Searches:    []string{"ai.internal"},
+	}
+	deployment, err := GetDeployment(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.DNSPolicy = v1.DNSNone
+		c.Spec.DNSConfig = dnsConfig
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, v1.DNSNone, deployment.Spec.Template.Spec.DNSPolicy)
+	assert.Equal(t, dnsConfig, deployment.Spec.Template.Spec.DNSConfig)
+
+	assert.NotEmpty(t, ValidateConfig(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.DNSPolicy = v1.DNSNone
+	})))
+}
+
+func Test_EphemeralContainerPolicy(t *testing.T) {
+	ephemeralRule := r1.PolicyRule{
+		APIGroups: []string{""},
+		Resources: []string{"pods/ephemeralcontainers"},
+		Verbs:     []string{"get", "patch", "update"},
+	}
+
+	clusterRole, err := GetClusterRole(newTestConfig(nil))
+	require.NoError(t, err)
+	assert.NotContains(t, clusterRole.Rules, ephemeralRule)
+
+	allowed := func(c *v1alpha1.K8sGPT) {
+		c.Spec.EphemeralContainerPolicy = &v1alpha1.EphemeralContainerPolicySpec{Allowed: true}
+	}
+	clusterRole, err = GetClusterRole(newTestConfig(allowed))
+	require.NoError(t, err)
+	assert.Contains(t, clusterRole.Rules, ephemeralRule)
+
+	// scoped to the watched namespaces, the Roles grant it instead
+	scoped := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		allowed(c)
+		c.Spec.WatchedNamespaces = []string{"team-a"}
+	})
+	clusterRole, err = GetClusterRole(scoped)
+	require.NoError(t, err)
+	assert.NotContains(t, clusterRole.Rules, ephemeralRule)
+	roles, err := GetRoles(scoped)
+	require.NoError(t, err)
+	require.Len(t, roles, 1)
+	assert.Contains(t, roles[0].Rules, ephemeralRule)
+}
+
+func Test_GetDeploymentOutputFormat(t *testing.T) {
+	deployment, err := GetDeployment(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.OutputFormat = "json"
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_OUTPUT_FORMAT", Value: "json"})
+
+	assert.NotEmpty(t, ValidateConfig(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.OutputFormat = "json"
+		c.Spec.Sink = &v1alpha1.WebhookRef{Type: "slack", Endpoint: "https://hooks.slack.com/services/x"}
+	})))
+}
+
+func Test_ServicePort(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.ServicePort = &v1alpha1.ServicePortSpec{
+			Port:       80,
+			TargetPort: intstr.FromInt(9090),
+			Protocol:   v1.ProtocolTCP,
+		}
+	})
+	service, err := GetService(config)
+	require.NoError(t, err)
+	assert.Equal(t, int32(80), service.Spec.Ports[0].Port)
+	assert.Equal(t, intstr.FromInt(9090), service.Spec.Ports[0].TargetPort)
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	container := deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, int32(9090), container.Ports[0].ContainerPort)
+	assert.Contains(t, container.Args, "--port=9090")
+
+	// a named target port names the container port
+	config = newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.ServicePort = &v1alpha1.ServicePortSpec{TargetPort: intstr.FromString("grpc")}
+	})
+	service, err = GetService(config)
+	require.NoError(t, err)
+	assert.Equal(t, int32(8080), service.Spec.Ports[0].Port)
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	container = deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "grpc", container.Ports[0].Name)
+	assert.Equal(t, int32(8080), container.Ports[0].ContainerPort)
+	assert.Equal(t, []string{"serve"}, container.Args)
+
+	assert.NotEmpty(t, ValidateConfig(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.ServicePort = &v1alpha1.ServicePortSpec{TargetPort: intstr.FromInt(70000)}
+	})))
+}
+
+func Test_GetDeploymentTopP(t *testing.T) {
+	tests := []struct {
+		topP    string
+		wantErr bool
+	}{
+		{topP: "0"},
+		{topP: "0.9"},
+		{topP: "1.0"},
+		{topP: "1.1", wantErr: true},
+		{topP: "-0.1", wantErr: true},
+		{topP: "high", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.topP, func(t *testing.T) {
+			topP := tt.topP
+			config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+				c.Spec.AI.TopP = &topP
+			})
+			if tt.wantErr {
+				assert.NotEmpty(t, ValidateConfig(config))
+				return
+			}
+			deployment, err := GetDeployment(config)
+			require.NoError(t, err)
+			assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+				v1.EnvVar{Name: "K8SGPT_TOP_P", Value: tt.topP})
+		})
+	}
+}
+
+// Benchmark_Sync simulates the latency of an API server, so that the time
+// spent waiting for the requests dominates
+func Benchmark_Sync(b *testing.B) {
+	scheme := runtime.NewScheme()
+	require.NoError(b, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+	config := newTestConfig(nil)
+	latency := interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			time.Sleep(time.Millisecond)
+			return c.Get(ctx, key, obj, opts...)
+		},
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			time.Sleep(time.Millisecond)
+			return c.Create(ctx, obj, opts...)
+		},
+	}
+
+	for i := 0; i < b.N; i++ {
+		fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(latency).Build()
+		require.NoError(b, Sync(ctx, fakeClient, config, SyncOp))
+	}
+}
+
+func Test_GetDeploymentSeedValue(t *testing.T) {
+	deployment, err := GetDeployment(newTestConfig(nil))
+	require.NoError(t, err)
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		assert.NotEqual(t, "K8SGPT_SEED", env.Name)
+	}
+
+	seed := int64(0)
+	deployment, err = GetDeployment(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.SeedValue = &seed
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, v1.EnvVar{Name: "K8SGPT_SEED", Value: "0"})
+}
+
+func Test_GetDeploymentLanguage(t *testing.T) {
+	deployment, err := GetDeployment(newTestConfig(nil))
+	require.NoError(t, err)
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		assert.NotEqual(t, "K8SGPT_LANGUAGE", env.Name)
+	}
+
+	deployment, err = GetDeployment(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.Language = "german"
+	}))
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, v1.EnvVar{Name: "K8SGPT_LANGUAGE", Value: "german"})
+}
+
+func Test_GetDeploymentAzureSovereignCloud(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.RemoteCache = &v1alpha1.RemoteCacheRef{
+			Credentials: &v1alpha1.CredentialsRef{Name: "k8sgpt-sample-cache-secret"},
+			Azure: &v1alpha1.AzureBackend{
+				StorageAccount:   "k8sgpt",
+				ContainerName:    "cache",
+				BlobEndpoint:     "https://k8sgpt.blob.core.usgovcloudapi.net/",
+				CloudEnvironment: "AzureGovernment",
+			},
+		}
+	})
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	assert.Contains(t, env, v1.EnvVar{Name: "AZURE_BLOB_ENDPOINT", Value: "https://k8sgpt.blob.core.usgovcloudapi.net/"})
+	assert.Contains(t, env, v1.EnvVar{Name: "AZURE_ENVIRONMENT", Value: "AzureGovernment"})
+	assert.Contains(t, env, v1.EnvVar{Name: "AZURE_AUTHORITY_HOST", Value: "https://login.microsoftonline.us/"})
+
+	// the public cloud is the default of the SDK
+	config.Spec.RemoteCache.Azure.CloudEnvironment = "AzurePublic"
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		assert.NotEqual(t, "AZURE_AUTHORITY_HOST", env.Name)
+	}
+
+	config.Spec.RemoteCache.Azure.BlobEndpoint = "k8sgpt.blob.core.usgovcloudapi.net"
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentObservability(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.Observability = &v1alpha1.ObservabilitySpec{
+			OTLPEndpoint: "http://otel-collector.observability.svc:4318",
+			OTLPHeaders:  map[string]string{"x-tenant": "team-a", "x-env": "prod"},
+			ServiceName:  "k8sgpt-sample",
+		}
+	})
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	assert.Contains(t, env, v1.EnvVar{Name: "OTEL_EXPORTER_OTLP_ENDPOINT", Value: "http://otel-collector.observability.svc:4318"})
+	assert.Contains(t, env, v1.EnvVar{Name: "OTEL_EXPORTER_OTLP_HEADERS", Value: "x-env=prod,x-tenant=team-a"})
+	assert.Contains(t, env, v1.EnvVar{Name: "OTEL_SERVICE_NAME", Value: "k8sgpt-sample"})
+
+	headersSecretRef := &v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "k8sgpt-otlp"},
+		Key:                  "headers",
+	}
+	config.Spec.Observability.OTLPHeadersSecretRef = headersSecretRef
+	assert.NotEmpty(t, ValidateConfig(config))
+
+	config.Spec.Observability.OTLPHeaders = nil
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env, v1.EnvVar{
+		Name:      "OTEL_EXPORTER_OTLP_HEADERS",
+		ValueFrom: &v1.EnvVarSource{SecretKeyRef: headersSecretRef},
+	})
+
+	config.Spec.Observability.OTLPEndpoint = "otel-collector:4318"
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentGRPCMaxMessageSize(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.GRPCMaxMessageSizeMB = 64
+	})
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_GRPC_MAX_MSG_SIZE", Value: "64"})
+
+	config.Spec.GRPCMaxMessageSizeMB = 1024
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentRuntimeTuning(t *testing.T) {
+	goGC := int32(200)
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.RuntimeTuning = &v1alpha1.RuntimeTuningSpec{
+			GoDebug: "madvdontneed=1",
+			GoGC:    &goGC,
+		}
+	})
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	assert.Contains(t, env, v1.EnvVar{Name: "GODEBUG", Value: "madvdontneed=1"})
+	assert.Contains(t, env, v1.EnvVar{Name: "GOGC", Value: "200"})
+
+	goGC = 5
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_SyncShouldRestartDeploymentOnSecretRotation(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-sample-secret", Namespace: "default"},
+		Data:       map[string][]byte{"openai-api-key": []byte("sk-1")},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+	ctx := context.Background()
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.Secret = &v1alpha1.SecretRef{Name: "k8sgpt-sample-secret", Key: "openai-api-key"}
+	})
+	key := client.ObjectKey{Name: DeploymentName, Namespace: "default"}
+
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	deployment := &appsv1.Deployment{}
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	hash := deployment.Spec.Template.Annotations[SecretHashAnnotation]
+	assert.NotEmpty(t, hash)
+
+	// an unchanged secret does not restart the pods, neither do changes of its metadata
+	secret.Labels = map[string]string{"team": "platform"}
+	require.NoError(t, fakeClient.Update(ctx, secret))
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	assert.Equal(t, hash, deployment.Spec.Template.Annotations[SecretHashAnnotation])
+
+	// the changed pod template rolls the pods
+	secret.Data["openai-api-key"] = []byte("sk-2")
+	require.NoError(t, fakeClient.Update(ctx, secret))
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	require.NoError(t, fakeClient.Get(ctx, key, deployment))
+	assert.NotEqual(t, hash, deployment.Spec.Template.Annotations[SecretHashAnnotation])
+}
+
+func Test_GetResourceQuota(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.ResourceQuota = &v1.ResourceQuotaSpec{
+			Hard: v1.ResourceList{
+				v1.ResourceLimitsCPU:    resource.MustParse("2"),
+				v1.ResourceLimitsMemory: resource.MustParse("1Gi"),
+			},
+		}
+	})
+
+	resourceQuota, err := GetResourceQuota(config)
+	require.NoError(t, err)
+	assert.Equal(t, "k8sgpt", resourceQuota.Name)
+	assert.Equal(t, "default", resourceQuota.Namespace)
+	assert.Equal(t, *config.Spec.ResourceQuota, resourceQuota.Spec)
+
+	objs, err := GetObjects(config)
+	require.NoError(t, err)
+	assert.Contains(t, objs, resourceQuota)
+
+	objs, err = GetObjects(newTestConfig(nil))
+	require.NoError(t, err)
+	for _, obj := range objs {
+		_, ok := obj.(*v1.ResourceQuota)
+		assert.False(t, ok)
+	}
+}
+
+func Test_GetDeploymentDisableTelemetry(t *testing.T) {
+	config := newTestConfig(nil)
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		assert.NotEqual(t, "K8SGPT_DISABLE_TELEMETRY", env.Name)
+	}
+
+	config.Spec.AI.DisableTelemetry = true
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_DISABLE_TELEMETRY", Value: "true"})
+}
+
+func Test_SyncWithResults(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.Background()
+	config := newTestConfig(nil)
+	operations := func(results []ResourceSyncResult) map[string]SyncOperation {
+		byKind := map[string]SyncOperation{}
+		for _, result := range results {
+			assert.NoError(t, result.Error)
+			byKind[result.ObjectRef.Kind] = result.Operation
+		}
+		return byKind
+	}
+
+	results, err := SyncWithResults(ctx, fakeClient, config, SyncOp)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]SyncOperation{
+		"Service":            SyncCreated,
+		"ServiceAccount":     SyncCreated,
+		"ClusterRole":        SyncCreated,
+		"ClusterRoleBinding": SyncCreated,
+		"Deployment":         SyncCreated,
+	}, operations(results))
+	for _, result := range results {
+		if result.ObjectRef.Kind == "Deployment" {
+			assert.Equal(t, "apps/v1", result.ObjectRef.APIVersion)
+			assert.Equal(t, DeploymentName, result.ObjectRef.Name)
+			assert.Equal(t, "default", result.ObjectRef.Namespace)
+		}
+	}
+
+	results, err = SyncWithResults(ctx, fakeClient, config, SyncOp)
+	require.NoError(t, err)
+	assert.Equal(t, SyncUnchanged, operations(results)["Deployment"])
+
+	config.Spec.Version = "v0.3.9"
+	results, err = SyncWithResults(ctx, fakeClient, config, SyncOp)
+	require.NoError(t, err)
+	assert.Equal(t, SyncUpdated, operations(results)["Deployment"])
+
+	results, err = SyncWithResults(ctx, fakeClient, config, DestroyOp)
+	require.NoError(t, err)
+	assert.Equal(t, SyncDeleted, operations(results)["Deployment"])
+}
+
+func Test_SyncWithResultsShouldReportTheFailedObject(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*r1.ClusterRole); ok {
+				return errors.NewForbidden(r1.Resource("clusterroles"), obj.GetName(), fmt.Errorf("escalation"))
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}).Build()
+
+	results, err := SyncWithResults(context.Background(), fakeClient, newTestConfig(nil), SyncOp)
+	require.Error(t, err)
+	var failed []ResourceSyncResult
+	for _, result := range results {
+		if result.Error != nil {
+			failed = append(failed, result)
+		}
+	}
+	require.Len(t, failed, 1)
+	assert.Equal(t, "ClusterRole", failed[0].ObjectRef.Kind)
+	assert.Equal(t, SyncFailed, failed[0].Operation)
+	// the deployment is not synced without its dependencies
+	for _, result := range results {
+		assert.NotEqual(t, "Deployment", result.ObjectRef.Kind)
+	}
+}
+
+func Test_GetDeploymentPlugins(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.Plugins = []v1alpha1.PluginSpec{
+			{
+				Name:    "trivy",
+				Enabled: true,
+				Config:  map[string]string{"namespace": "trivy-system", "skip-install": "true"},
+			},
+			{Name: "prometheus"},
+		}
+	})
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	env := deployment.Spec.Template.Spec.Containers[0].Env
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_PLUGIN_TRIVY_ENABLED", Value: "true"})
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_PLUGIN_TRIVY_CONFIG_NAMESPACE", Value: "trivy-system"})
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_PLUGIN_TRIVY_CONFIG_SKIP_INSTALL", Value: "true"})
+	assert.Contains(t, env, v1.EnvVar{Name: "K8SGPT_PLUGIN_PROMETHEUS_ENABLED", Value: "false"})
+
+	config.Spec.AI.Plugins = append(config.Spec.AI.Plugins, v1alpha1.PluginSpec{Name: "Trivy"})
+	assert.NotEmpty(t, ValidateConfig(config))
+
+	config.Spec.AI.Plugins = []v1alpha1.PluginSpec{{Name: "trivy-operator"}}
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetSecretHash(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-sample-secret", Namespace: "default"},
+		Data:       map[string][]byte{"openai-api-key": []byte("sk-1")},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+	ctx := context.Background()
+	ref := v1.SecretKeySelector{
+		LocalObjectReference: v1.LocalObjectReference{Name: "k8sgpt-sample-secret"},
+		Key:                  "openai-api-key",
+	}
+
+	hash, err := GetSecretHash(ctx, fakeClient, ref, "default")
+	require.NoError(t, err)
+	// sha256 of sk-1
+	assert.Equal(t, "0f2c10bf3d128c719c6bfa4ecbae94b7fceebaea6e4438fef38a90e5acc326f3", hash)
+
+	ref.Key = "azure-api-key"
+	_, err = GetSecretHash(ctx, fakeClient, ref, "default")
+	assert.Error(t, err)
+
+	_, err = GetSecretHash(ctx, fakeClient, ref, "k8sgpt-operator-system")
+	assert.True(t, errors.IsNotFound(err))
+}
+
+func Test_GetDeploymentProbes(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.StartupProbe = v1alpha1.DefaultStartupProbe()
+		c.Spec.LivenessProbe = &v1.Probe{
+			ProbeHandler: v1.ProbeHandler{
+				HTTPGet: &v1.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(8081)},
+			},
+			InitialDelaySeconds: 60,
+		}
+	})
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	container := deployment.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, config.Spec.StartupProbe, container.StartupProbe)
+	assert.Equal(t, config.Spec.LivenessProbe, container.LivenessProbe)
+
+	// 3 failures every 10 seconds restart k8sgpt before the liveness probe starts
+	config.Spec.StartupProbe.FailureThreshold = 0
+	config.Spec.StartupProbe.PeriodSeconds = 0
+	assert.NotEmpty(t, ValidateConfig(config))
+
+	// without the webhook, the defaults are applied to the deployment
+	config.Spec.StartupProbe = nil
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.DefaultStartupProbe(), deployment.Spec.Template.Spec.Containers[0].StartupProbe)
+}
+
+func Test_GetDeploymentContainerSecurityContext(t *testing.T) {
+	deployment, err := GetDeployment(newTestConfig(nil))
+	require.NoError(t, err)
+	assert.Equal(t, v1alpha1.DefaultContainerSecurityContext(),
+		deployment.Spec.Template.Spec.Containers[0].SecurityContext)
+
+	runAsNonRoot := false
+	securityContext := &v1.SecurityContext{RunAsNonRoot: &runAsNonRoot}
+	deployment, err = GetDeployment(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.ContainerSecurityContext = securityContext
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, securityContext, deployment.Spec.Template.Spec.Containers[0].SecurityContext)
+}
+
+func Test_GetDeploymentLocalModel(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.Backend = v1alpha1.LocalAI
+		c.Spec.AI.LocalModelPath = "/models/llama-2-7b.gguf"
+	})
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	podSpec := deployment.Spec.Template.Spec
+	assert.Contains(t, podSpec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_LOCAL_MODEL_PATH", Value: "/models/llama-2-7b.gguf"})
+	assert.Contains(t, podSpec.Containers[0].VolumeMounts,
+		v1.VolumeMount{Name: "k8sgpt-model", MountPath: "/models/llama-2-7b.gguf", ReadOnly: true})
+	require.Len(t, podSpec.Volumes, 2)
+	require.NotNil(t, podSpec.Volumes[1].HostPath)
+	assert.Equal(t, "/models/llama-2-7b.gguf", podSpec.Volumes[1].HostPath.Path)
+
+	// the claim holds the model file, it is mounted at its directory
+	config.Spec.AI.LocalModelClaimName = "models"
+	deployment, err = GetDeployment(config)
+	require.NoError(t, err)
+	podSpec = deployment.Spec.Template.Spec
+	assert.Contains(t, podSpec.Containers[0].VolumeMounts,
+		v1.VolumeMount{Name: "k8sgpt-model", MountPath: "/models", ReadOnly: true})
+	require.NotNil(t, podSpec.Volumes[1].PersistentVolumeClaim)
+	assert.Equal(t, "models", podSpec.Volumes[1].PersistentVolumeClaim.ClaimName)
+
+	config.Spec.AI.LocalModelPath = "/llama-2-7b.gguf"
+	assert.NotEmpty(t, ValidateConfig(config))
+
+	config.Spec.AI.LocalModelPath = "models/llama-2-7b.gguf"
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentRemoteKubeconfig(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.RemoteKubeconfig = &v1.SecretReference{Name: "spoke-kubeconfig"}
+	})
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	podSpec := deployment.Spec.Template.Spec
+	assert.Contains(t, podSpec.Containers[0].Env, v1.EnvVar{Name: "KUBECONFIG", Value: "/remote/kubeconfig"})
+	assert.Contains(t, podSpec.Containers[0].VolumeMounts,
+		v1.VolumeMount{Name: "k8sgpt-remote-kubeconfig", MountPath: "/remote", ReadOnly: true})
+	assert.Contains(t, podSpec.Volumes, v1.Volume{
+		Name: "k8sgpt-remote-kubeconfig",
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: "spoke-kubeconfig"},
+		},
+	})
+
+	config.Spec.RemoteKubeconfig.Namespace = "kube-system"
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_SyncShouldRequireTheRemoteKubeconfig(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "spoke-kubeconfig", Namespace: "default"},
+		Data:       map[string][]byte{"config": []byte("apiVersion: v1")},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.Background()
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.RemoteKubeconfig = &v1.SecretReference{Name: "spoke-kubeconfig"}
+	})
+
+	assert.ErrorContains(t, Sync(ctx, fakeClient, config, SyncOp), "remote kubeconfig secret does not exist")
+
+	require.NoError(t, fakeClient.Create(ctx, secret))
+	assert.ErrorContains(t, Sync(ctx, fakeClient, config, SyncOp), "no key kubeconfig")
+
+	secret.Data = map[string][]byte{"kubeconfig": []byte("apiVersion: v1")}
+	require.NoError(t, fakeClient.Update(ctx, secret))
+	assert.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+}
+
+func Test_GetDeploymentConversationHistory(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.ConversationHistory = true
+	})
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_CONVERSATION_HISTORY", Value: "true"})
+}
+
+func Test_GetImage(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.Repository = "ghcr.io/k8sgpt-ai/k8sgpt"
+		c.Spec.Version = "v0.3.8"
+	})
+	// not migrated by the webhook yet
+	assert.Equal(t, v1alpha1.ImageSpec{Repository: "ghcr.io/k8sgpt-ai/k8sgpt", Version: "v0.3.8"}, GetImage(config))
+
+	config.Spec.Image = &v1alpha1.ImageSpec{Version: "v0.3.9"}
+	assert.Equal(t, v1alpha1.ImageSpec{Repository: "ghcr.io/k8sgpt-ai/k8sgpt", Version: "v0.3.9"}, GetImage(config))
+
+	config.Spec.Image.Repository = "registry.example.com/k8sgpt"
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Equal(t, "registry.example.com/k8sgpt:v0.3.9", deployment.Spec.Template.Spec.Containers[0].Image)
+}
+
+func Test_GetDeploymentRemoteCacheTTL(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.RemoteCache = &v1alpha1.RemoteCacheRef{
+			Redis: &v1alpha1.RedisBackend{Address: "redis:6379"},
+			TTL:   &metav1.Duration{Duration: 90 * time.Minute},
+		}
+	})
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_CACHE_TTL", Value: "5400"})
+
+	config.Spec.RemoteCache.TTL.Duration = 0
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetDeploymentResponseTimeout(t *testing.T) {
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.ResponseTimeout = &metav1.Duration{Duration: 2 * time.Minute}
+	})
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Env,
+		v1.EnvVar{Name: "K8SGPT_RESPONSE_TIMEOUT", Value: "120"})
+
+	config.Spec.AI.ResponseTimeout.Duration = time.Second
+	assert.NotEmpty(t, ValidateConfig(config))
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resources
+
+import (
+	"fmt"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	MetricsPort     = 8081
+	MetricsPortName = "metrics"
+)
+
+// The Prometheus Operator types are not vendored, its objects are handled as unstructured
+var ServiceMonitorGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "ServiceMonitor",
+}
+
+var PodMonitorGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "PodMonitor",
+}
+
+var PrometheusRuleGVK = schema.GroupVersionKind{
+	Group:   "monitoring.coreos.com",
+	Version: "v1",
+	Kind:    "PrometheusRule",
+}
+
+func monitoringEnabled(config v1alpha1.K8sGPT) bool {
+	return config.Spec.Monitoring != nil && config.Spec.Monitoring.Enabled
+}
+
+// GetServiceMonitor Create ServiceMonitor scraping the K8sGPT metrics
+func GetServiceMonitor(config v1alpha1.K8sGPT) (*unstructured.Unstructured, error) {
+	endpoint := map[string]interface{}{
+		"port": MetricsPortName,
+	}
+	if config.Spec.Monitoring.Interval != "" {
+		endpoint["interval"] = config.Spec.Monitoring.Interval
+	}
+
+	serviceMonitor := &unstructured.Unstructured{}
+	serviceMonitor.SetGroupVersionKind(ServiceMonitorGVK)
+	serviceMonitor.SetName("k8sgpt")
+	serviceMonitor.SetNamespace(GetTargetNamespace(config))
+	serviceMonitor.SetOwnerReferences([]metav1.OwnerReference{ComputeOwnerReference(config)})
+	serviceMonitor.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				"app": DeploymentName,
+			},
+		},
+		"namespaceSelector": map[string]interface{}{
+			"matchNames": []interface{}{GetTargetNamespace(config)},
+		},
+		"endpoints": []interface{}{endpoint},
+	}
+
+	return serviceMonitor, nil
+}
+
+// GetPodMonitor Create PodMonitor scraping the K8sGPT metrics from the pod directly
+func GetPodMonitor(config v1alpha1.K8sGPT) (*unstructured.Unstructured, error) {
+	endpoint := map[string]interface{}{
+		"port": MetricsPortName,
+	}
+	if config.Spec.Monitoring.Interval != "" {
+		endpoint["interval"] = config.Spec.Monitoring.Interval
+	}
+
+	podMonitor := &unstructured.Unstructured{}
+	podMonitor.SetGroupVersionKind(PodMonitorGVK)
+	podMonitor.SetName("k8sgpt")
+	podMonitor.SetNamespace(GetTargetNamespace(config))
+	podMonitor.SetOwnerReferences([]metav1.OwnerReference{ComputeOwnerReference(config)})
+	podMonitor.Object["spec"] = map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": map[string]interface{}{
+				"app": DeploymentName,
+			},
+		},
+		"namespaceSelector": map[string]interface{}{
+			"matchNames": []interface{}{GetTargetNamespace(config)},
+		},
+		"podMetricsEndpoints": []interface{}{endpoint},
+	}
+
+	return podMonitor, nil
+}
+
+func alertingRulesEnabled(config v1alpha1.K8sGPT) bool {
+	return config.Spec.AlertingRules != nil && config.Spec.AlertingRules.Enabled
+}
+
+// GetPrometheusRule Create PrometheusRule alerting on the number of K8sGPT results
+func GetPrometheusRule(config v1alpha1.K8sGPT) (*unstructured.Unstructured, error) {
+	alertingRules := config.Spec.AlertingRules
+	duration := alertingRules.For
+	if duration == "" {
+		duration = "15m"
+	}
+	severity := alertingRules.Severity
+	if severity == "" {
+		severity = "warning"
+	}
+
+	prometheusRule := &unstructured.Unstructured{}
+	prometheusRule.SetGroupVersionKind(PrometheusRuleGVK)
+	prometheusRule.SetName("k8sgpt")
+	prometheusRule.SetNamespace(GetTargetNamespace(config))
+	prometheusRule.SetOwnerReferences([]metav1.OwnerReference{ComputeOwnerReference(config)})
+	prometheusRule.Object["spec"] = map[string]interface{}{
+		"groups": []interface{}{
+			map[string]interface{}{
+				"name": "k8sgpt",
+				"rules": []interface{}{
+					map[string]interface{}{
+						"alert": "K8sGPTResultsAboveThreshold",
+						// the results of the other K8sGPT instances have their own rules
+						"expr": fmt.Sprintf(`k8sgpt_number_of_results{name="%s",namespace="%s"} > %d`,
+							config.Name, config.Namespace, alertingRules.ResultsThreshold),
+						"for": duration,
+						"labels": map[string]interface{}{
+							"severity": severity,
+						},
+						"annotations": map[string]interface{}{
+							"summary": "K8sGPT found issues in the cluster",
+							"description": "K8sGPT reports {{ $value }} results, " +
+								"inspect them with kubectl get results -A.",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return prometheusRule, nil
+}
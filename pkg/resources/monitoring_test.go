@@ -0,0 +1,181 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func Test_GetServiceMonitor(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			Monitoring: &v1alpha1.MonitoringSpec{
+				Enabled:  true,
+				Interval: "30s",
+			},
+		},
+	}
+
+	serviceMonitor, err := GetServiceMonitor(config)
+	require.NoError(t, err)
+	assert.Equal(t, ServiceMonitorGVK, serviceMonitor.GroupVersionKind())
+	assert.Equal(t, "default", serviceMonitor.GetNamespace())
+	endpoints, _, err := unstructured.NestedSlice(serviceMonitor.Object, "spec", "endpoints")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"port": MetricsPortName, "interval": "30s"},
+	}, endpoints)
+
+	// the service must expose the port the ServiceMonitor scrapes
+	svc, err := GetService(config)
+	require.NoError(t, err)
+	require.Len(t, svc.Spec.Ports, 2)
+	assert.Equal(t, MetricsPortName, svc.Spec.Ports[1].Name)
+}
+
+func Test_GetPodMonitor(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			Monitoring: &v1alpha1.MonitoringSpec{
+				Enabled:    true,
+				PodMonitor: true,
+			},
+		},
+	}
+
+	podMonitor, err := GetPodMonitor(config)
+	require.NoError(t, err)
+	assert.Equal(t, PodMonitorGVK, podMonitor.GroupVersionKind())
+	assert.Equal(t, "default", podMonitor.GetNamespace())
+	endpoints, _, err := unstructured.NestedSlice(podMonitor.Object, "spec", "podMetricsEndpoints")
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"port": MetricsPortName}}, endpoints)
+
+	// the PodMonitor replaces the ServiceMonitor
+	objs, err := GetObjects(config)
+	require.NoError(t, err)
+	var kinds []string
+	for _, obj := range objs {
+		kinds = append(kinds, obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+	assert.Contains(t, kinds, "PodMonitor")
+	assert.NotContains(t, kinds, "ServiceMonitor")
+
+	// the pod must expose the port the PodMonitor scrapes
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.Contains(t, deployment.Spec.Template.Spec.Containers[0].Ports,
+		v1.ContainerPort{Name: MetricsPortName, ContainerPort: MetricsPort})
+}
+
+func Test_SyncShouldSkipServiceMonitorWithoutCRD(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	ctx := context.Background()
+
+	// behave like an API server without the Prometheus Operator CRDs
+	noMatch := func(obj client.Object) error {
+		if obj.GetObjectKind().GroupVersionKind() == ServiceMonitorGVK {
+			return &meta.NoKindMatchError{GroupKind: ServiceMonitorGVK.GroupKind()}
+		}
+		return nil
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if err := noMatch(obj); err != nil {
+				return err
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			if err := noMatch(obj); err != nil {
+				return err
+			}
+			return c.Delete(ctx, obj, opts...)
+		},
+	}).Build()
+
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			Monitoring: &v1alpha1.MonitoringSpec{
+				Enabled: true,
+			},
+		},
+	}
+
+	require.NoError(t, Sync(ctx, fakeClient, config, SyncOp))
+	// the remaining objects are still created
+	require.NoError(t, fakeClient.Get(ctx, client.ObjectKey{Name: DeploymentName, Namespace: "default"}, &appsv1.Deployment{}))
+	require.NoError(t, Sync(ctx, fakeClient, config, DestroyOp))
+}
+
+func Test_GetPrometheusRule(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			AlertingRules: &v1alpha1.AlertingRulesSpec{
+				Enabled:          true,
+				ResultsThreshold: 5,
+				Severity:         "critical",
+			},
+		},
+	}
+
+	prometheusRule, err := GetPrometheusRule(config)
+	require.NoError(t, err)
+	assert.Equal(t, PrometheusRuleGVK, prometheusRule.GroupVersionKind())
+	groups, _, err := unstructured.NestedSlice(prometheusRule.Object, "spec", "groups")
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	rules, _, err := unstructured.NestedSlice(groups[0].(map[string]interface{}), "rules")
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	rule := rules[0].(map[string]interface{})
+	assert.Equal(t, `k8sgpt_number_of_results{name="k8sgpt-sample",namespace="default"} > 5`, rule["expr"])
+	assert.Equal(t, "15m", rule["for"])
+	assert.Equal(t, map[string]interface{}{"severity": "critical"}, rule["labels"])
+
+	objs, err := GetObjects(config)
+	require.NoError(t, err)
+	assert.Contains(t, objs, prometheusRule)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resources
+
+import (
+	"os"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// OperatorNamespaceEnv is set to the namespace of the operator by the downward API
+const OperatorNamespaceEnv = "OPERATOR_NAMESPACE"
+
+func networkPolicyEnabled(config v1alpha1.K8sGPT) bool {
+	return config.Spec.EgressPolicy != nil || config.Spec.DenyIngressFromAllNamespaces
+}
+
+// operatorNamespace the operator connects to the K8sGPT pod from. Outside of
+// the cluster it is unknown, the namespace of the K8sGPT instance is used then.
+func operatorNamespace(config v1alpha1.K8sGPT) string {
+	if namespace := os.Getenv(OperatorNamespaceEnv); namespace != "" {
+		return namespace
+	}
+	return config.Namespace
+}
+
+// GetNetworkPolicy Create NetworkPolicy restricting the egress and ingress of the K8sGPT pod
+func GetNetworkPolicy(config v1alpha1.K8sGPT) (*networkingv1.NetworkPolicy, error) {
+	networkPolicy := networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "k8sgpt",
+			Namespace:       GetTargetNamespace(config),
+			OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": DeploymentName,
+				},
+			},
+		},
+	}
+
+	if config.Spec.DenyIngressFromAllNamespaces {
+		// only the operator queries the k8sgpt server
+		networkPolicy.Spec.PolicyTypes = append(networkPolicy.Spec.PolicyTypes, networkingv1.PolicyTypeIngress)
+		networkPolicy.Spec.Ingress = []networkingv1.NetworkPolicyIngressRule{
+			{
+				From: []networkingv1.NetworkPolicyPeer{
+					{
+						NamespaceSelector: &metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								corev1.LabelMetadataName: operatorNamespace(config),
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	egressPolicy := config.Spec.EgressPolicy
+	if egressPolicy == nil {
+		return &networkPolicy, nil
+	}
+
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, cidr := range egressPolicy.AllowedCIDRs {
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			IPBlock: &networkingv1.IPBlock{CIDR: cidr},
+		})
+	}
+	var ports []networkingv1.NetworkPolicyPort
+	for _, port := range egressPolicy.AllowedPorts {
+		p := intstr.FromInt(int(port))
+		ports = append(ports, networkingv1.NetworkPolicyPort{Port: &p})
+	}
+
+	// name resolution has to keep working, whatever the allowed CIDRs are
+	dnsPort := intstr.FromInt(53)
+	udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+	egress := []networkingv1.NetworkPolicyEgressRule{
+		{
+			Ports: []networkingv1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &dnsPort},
+				{Protocol: &tcp, Port: &dnsPort},
+			},
+		},
+	}
+	if len(peers) > 0 {
+		egress = append(egress, networkingv1.NetworkPolicyEgressRule{
+			To:    peers,
+			Ports: ports,
+		})
+	}
+	networkPolicy.Spec.PolicyTypes = append(networkPolicy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+	networkPolicy.Spec.Egress = egress
+
+	return &networkPolicy, nil
+}
@@ -0,0 +1,96 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_GetNetworkPolicy(t *testing.T) {
+	config := v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "k8sgpt-sample",
+			Namespace: "default",
+		},
+		Spec: v1alpha1.K8sGPTSpec{
+			AI: &v1alpha1.AISpec{
+				Backend: v1alpha1.OpenAI,
+			},
+			EgressPolicy: &v1alpha1.EgressPolicySpec{
+				AllowedCIDRs: []string{"10.96.0.1/32", "104.18.0.0/16"},
+				AllowedPorts: []int32{443},
+			},
+		},
+	}
+
+	networkPolicy, err := GetNetworkPolicy(config)
+	require.NoError(t, err)
+	assert.Equal(t, "default", networkPolicy.Namespace)
+	assert.Equal(t, DeploymentName, networkPolicy.Spec.PodSelector.MatchLabels["app"])
+	assert.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}, networkPolicy.Spec.PolicyTypes)
+	// the DNS rule and the rule for the allowed CIDRs
+	require.Len(t, networkPolicy.Spec.Egress, 2)
+	assert.Equal(t, 53, networkPolicy.Spec.Egress[0].Ports[0].Port.IntValue())
+	rule := networkPolicy.Spec.Egress[1]
+	require.Len(t, rule.To, 2)
+	assert.Equal(t, "104.18.0.0/16", rule.To[1].IPBlock.CIDR)
+	require.Len(t, rule.Ports, 1)
+	assert.Equal(t, 443, rule.Ports[0].Port.IntValue())
+
+	objs, err := GetObjects(config)
+	require.NoError(t, err)
+	assert.Contains(t, objs, networkPolicy)
+
+	config.Spec.EgressPolicy.AllowedCIDRs = []string{"104.18.0.0"}
+	assert.NotEmpty(t, ValidateConfig(config))
+
+	config.Spec.EgressPolicy.AllowedCIDRs = []string{"104.18.0.0/16"}
+	config.Spec.EgressPolicy.AllowedPorts = []int32{70000}
+	assert.NotEmpty(t, ValidateConfig(config))
+
+	// k8sgpt could not reach the API server
+	config.Spec.EgressPolicy = &v1alpha1.EgressPolicySpec{}
+	assert.NotEmpty(t, ValidateConfig(config))
+	config.Spec.EgressPolicy.AllowedPorts = []int32{443}
+	assert.NotEmpty(t, ValidateConfig(config))
+}
+
+func Test_GetNetworkPolicyDenyIngress(t *testing.T) {
+	t.Setenv(OperatorNamespaceEnv, "k8sgpt-operator-system")
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.DenyIngressFromAllNamespaces = true
+	})
+
+	networkPolicy, err := GetNetworkPolicy(config)
+	require.NoError(t, err)
+	assert.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}, networkPolicy.Spec.PolicyTypes)
+	assert.Empty(t, networkPolicy.Spec.Egress)
+	require.Len(t, networkPolicy.Spec.Ingress, 1)
+	require.Len(t, networkPolicy.Spec.Ingress[0].From, 1)
+	assert.Equal(t, map[string]string{"kubernetes.io/metadata.name": "k8sgpt-operator-system"},
+		networkPolicy.Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels)
+
+	objs, err := GetObjects(config)
+	require.NoError(t, err)
+	assert.Contains(t, objs, networkPolicy)
+
+	// both rules are in the same NetworkPolicy
+	config.Spec.EgressPolicy = &v1alpha1.EgressPolicySpec{AllowedCIDRs: []string{"104.18.0.0/16"}}
+	networkPolicy, err = GetNetworkPolicy(config)
+	require.NoError(t, err)
+	assert.Equal(t, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		networkPolicy.Spec.PolicyTypes)
+	assert.Len(t, networkPolicy.Spec.Ingress, 1)
+	assert.Len(t, networkPolicy.Spec.Egress, 2)
+
+	// outside of the cluster, the namespace of the instance is allowed
+	t.Setenv(OperatorNamespaceEnv, "")
+	networkPolicy, err = GetNetworkPolicy(config)
+	require.NoError(t, err)
+	assert.Equal(t, "default",
+		networkPolicy.Spec.Ingress[0].From[0].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"])
+}
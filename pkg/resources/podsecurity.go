@@ -0,0 +1,69 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resources
+
+import (
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	PodSecurityEnforceLabel = "pod-security.kubernetes.io/enforce"
+	PodSecurityRestricted   = "restricted"
+)
+
+// GetRestrictedViolations returns the requirements of the restricted Pod Security
+// Standard the container security context does not meet
+func GetRestrictedViolations(sc *corev1.SecurityContext) []string {
+	if sc == nil {
+		sc = &corev1.SecurityContext{}
+	}
+
+	var violations []string
+	if sc.RunAsNonRoot == nil || !*sc.RunAsNonRoot {
+		violations = append(violations, "runAsNonRoot must be true")
+	}
+	if sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+		violations = append(violations, "runAsUser must not be 0")
+	}
+	if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+		violations = append(violations, "allowPrivilegeEscalation must be false")
+	}
+	if sc.Privileged != nil && *sc.Privileged {
+		violations = append(violations, "privileged must be false")
+	}
+	dropsAll := false
+	if sc.Capabilities != nil {
+		for _, capability := range sc.Capabilities.Drop {
+			if capability == "ALL" {
+				dropsAll = true
+			}
+		}
+		for _, capability := range sc.Capabilities.Add {
+			if capability != "NET_BIND_SERVICE" {
+				violations = append(violations, "capability "+string(capability)+" must not be added")
+			}
+		}
+	}
+	if !dropsAll {
+		violations = append(violations, "capabilities must drop ALL")
+	}
+	if sc.SeccompProfile == nil ||
+		(sc.SeccompProfile.Type != corev1.SeccompProfileTypeRuntimeDefault &&
+			sc.SeccompProfile.Type != corev1.SeccompProfileTypeLocalhost) {
+		violations = append(violations, "seccompProfile must be RuntimeDefault or Localhost")
+	}
+
+	return violations
+}
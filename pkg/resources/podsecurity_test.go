@@ -0,0 +1,30 @@
+package resources
+
+import (
+	"testing"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+)
+
+func Test_GetRestrictedViolations(t *testing.T) {
+	// the default complies with the restricted Pod Security Standard
+	assert.Empty(t, GetRestrictedViolations(v1alpha1.DefaultContainerSecurityContext()))
+
+	assert.ElementsMatch(t, []string{
+		"runAsNonRoot must be true",
+		"allowPrivilegeEscalation must be false",
+		"capabilities must drop ALL",
+		"seccompProfile must be RuntimeDefault or Localhost",
+	}, GetRestrictedViolations(nil))
+
+	sc := v1alpha1.DefaultContainerSecurityContext()
+	sc.RunAsUser = pointer.Int64(0)
+	sc.Capabilities.Add = []v1.Capability{"NET_ADMIN"}
+	assert.ElementsMatch(t, []string{
+		"runAsUser must not be 0",
+		"capability NET_ADMIN must not be added",
+	}, GetRestrictedViolations(sc))
+}
@@ -0,0 +1,123 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resources
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	r1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const PodSecurityPolicyRoleName = "k8sgpt-psp"
+
+// PodSecurityPolicySupported reports whether the cluster still serves
+// PodSecurityPolicies, they were removed in Kubernetes 1.25
+func PodSecurityPolicySupported(info *version.Info) bool {
+	if info == nil {
+		return false
+	}
+	// managed clusters report minor versions like 24+
+	major, er := strconv.Atoi(strings.TrimSuffix(info.Major, "+"))
+	if er != nil {
+		return false
+	}
+	minor, er := strconv.Atoi(strings.TrimSuffix(info.Minor, "+"))
+	if er != nil {
+		return false
+	}
+	return major == 1 && minor < 25
+}
+
+// GetPodSecurityPolicyRole Create a Role allowing the use of the PodSecurityPolicy
+func GetPodSecurityPolicyRole(config v1alpha1.K8sGPT) (*r1.Role, error) {
+	role := r1.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            PodSecurityPolicyRoleName,
+			Namespace:       GetTargetNamespace(config),
+			OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
+		},
+		Rules: []r1.PolicyRule{
+			{
+				APIGroups:     []string{"policy"},
+				Resources:     []string{"podsecuritypolicies"},
+				ResourceNames: []string{config.Spec.PodSecurityPolicyName},
+				Verbs:         []string{"use"},
+			},
+		},
+	}
+
+	return &role, nil
+}
+
+// GetPodSecurityPolicyRoleBinding Create a RoleBinding of the PodSecurityPolicy Role to the k8sgpt ServiceAccount
+func GetPodSecurityPolicyRoleBinding(config v1alpha1.K8sGPT) (*r1.RoleBinding, error) {
+	roleBinding := r1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            PodSecurityPolicyRoleName,
+			Namespace:       GetTargetNamespace(config),
+			OwnerReferences: []metav1.OwnerReference{ComputeOwnerReference(config)},
+		},
+		Subjects: []r1.Subject{
+			{
+				Kind:      "ServiceAccount",
+				Name:      "k8sgpt",
+				Namespace: GetTargetNamespace(config),
+			},
+		},
+		RoleRef: r1.RoleRef{
+			Kind:     "Role",
+			Name:     PodSecurityPolicyRoleName,
+			APIGroup: "rbac.authorization.k8s.io",
+		},
+	}
+
+	return &roleBinding, nil
+}
+
+// SyncPodSecurityPolicy creates or destroys the Role and RoleBinding of the
+// PodSecurityPolicy. They are not part of Sync, as only the caller can tell
+// whether the cluster still supports PodSecurityPolicies.
+func SyncPodSecurityPolicy(ctx context.Context, c client.Client, config v1alpha1.K8sGPT, i SyncOrDestroy) error {
+	role, er := GetPodSecurityPolicyRole(config)
+	if er != nil {
+		return er
+	}
+	roleBinding, er := GetPodSecurityPolicyRoleBinding(config)
+	if er != nil {
+		return er
+	}
+
+	for _, obj := range orderObjects([]client.Object{role, roleBinding}, i) {
+		switch i {
+		case SyncOp:
+			if _, er := doSync(ctx, c, obj); er != nil && !errors.IsAlreadyExists(er) {
+				return er
+			}
+		case DestroyOp:
+			if er := c.Delete(ctx, obj); er != nil && !errors.IsNotFound(er) {
+				return er
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	r1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_PodSecurityPolicySupported(t *testing.T) {
+	tests := []struct {
+		name string
+		info *version.Info
+		want bool
+	}{
+		{name: "unknown", info: nil, want: false},
+		{name: "1.24", info: &version.Info{Major: "1", Minor: "24"}, want: true},
+		{name: "managed 1.24", info: &version.Info{Major: "1", Minor: "24+"}, want: true},
+		{name: "1.25", info: &version.Info{Major: "1", Minor: "25"}, want: false},
+		{name: "1.28", info: &version.Info{Major: "1", Minor: "28"}, want: false},
+		{name: "malformed", info: &version.Info{Major: "1", Minor: "x"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, PodSecurityPolicySupported(tt.info))
+		})
+	}
+}
+
+func Test_SyncPodSecurityPolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.Background()
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.PodSecurityPolicyName = "restricted"
+	})
+	key := client.ObjectKey{Name: PodSecurityPolicyRoleName, Namespace: "default"}
+
+	require.NoError(t, SyncPodSecurityPolicy(ctx, fakeClient, config, SyncOp))
+	role := &r1.Role{}
+	require.NoError(t, fakeClient.Get(ctx, key, role))
+	assert.Equal(t, []string{"restricted"}, role.Rules[0].ResourceNames)
+	assert.Equal(t, []string{"use"}, role.Rules[0].Verbs)
+	roleBinding := &r1.RoleBinding{}
+	require.NoError(t, fakeClient.Get(ctx, key, roleBinding))
+	assert.Equal(t, "k8sgpt", roleBinding.Subjects[0].Name)
+
+	// a second sync is a no-op
+	require.NoError(t, SyncPodSecurityPolicy(ctx, fakeClient, config, SyncOp))
+
+	require.NoError(t, SyncPodSecurityPolicy(ctx, fakeClient, config, DestroyOp))
+	assert.True(t, errors.IsNotFound(fakeClient.Get(ctx, key, &r1.Role{})))
+	assert.True(t, errors.IsNotFound(fakeClient.Get(ctx, key, &r1.RoleBinding{})))
+	require.NoError(t, SyncPodSecurityPolicy(ctx, fakeClient, config, DestroyOp))
+}
@@ -30,10 +30,7 @@ func MapResults(i integrations.Integrations, resultsSpec []v1alpha1.ResultSpec,
 		name := strings.ReplaceAll(resultSpec.Name, "-", "")
 		name = strings.ReplaceAll(name, "/", "")
 		result := GetResult(resultSpec, name, namespace, backend)
-		labels := map[string]string{
-			"k8sgpts.k8sgpt.ai/name":      config.Name,
-			"k8sgpts.k8sgpt.ai/namespace": config.Namespace,
-		}
+		labels := ownerLabels(config)
 		if config.Spec.AI != nil {
 			labels["k8sgpts.k8sgpt.ai/backend"] = config.Spec.AI.Backend
 		}
@@ -0,0 +1,115 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resources
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	// the operator image has no time zone database
+	_ "time/tzdata"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+)
+
+// updateWindow is a time of day range, it spans midnight when end is not after start
+type updateWindow struct {
+	start, end time.Duration
+}
+
+// parseUpdateWindow parses a window like 02:00-04:00
+func parseUpdateWindow(window string) (updateWindow, error) {
+	start, end, ok := strings.Cut(window, "-")
+	if !ok {
+		return updateWindow{}, fmt.Errorf("AllowedHours %q must be a range like 02:00-04:00.", window)
+	}
+	var w updateWindow
+	for _, t := range []struct {
+		value string
+		into  *time.Duration
+	}{{start, &w.start}, {end, &w.end}} {
+		parsed, er := time.Parse("15:04", t.value)
+		if er != nil {
+			return updateWindow{}, fmt.Errorf("AllowedHours %q must be a range like 02:00-04:00.", window)
+		}
+		*t.into = time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute
+	}
+	return w, nil
+}
+
+func updatePolicyLocation(policy *v1alpha1.UpdatePolicySpec) (*time.Location, error) {
+	if policy.Timezone == "" {
+		return time.UTC, nil
+	}
+	location, er := time.LoadLocation(policy.Timezone)
+	if er != nil {
+		return nil, fmt.Errorf("Timezone %s is not a known time zone.", policy.Timezone)
+	}
+	return location, nil
+}
+
+// NextUpdateWindow returns how long changes have to wait for the next window of
+// the update policy, zero if they can be applied now or there is no policy
+func NextUpdateWindow(policy *v1alpha1.UpdatePolicySpec, now time.Time) (time.Duration, error) {
+	if policy == nil || len(policy.AllowedHours) == 0 {
+		return 0, nil
+	}
+	location, er := updatePolicyLocation(policy)
+	if er != nil {
+		return 0, er
+	}
+	now = now.In(location)
+
+	var wait time.Duration = -1
+	for _, allowedHours := range policy.AllowedHours {
+		window, er := parseUpdateWindow(allowedHours)
+		if er != nil {
+			return 0, er
+		}
+		// a window of yesterday may still be open, one of tomorrow is the latest to open
+		for day := -1; day <= 1; day++ {
+			midnight := time.Date(now.Year(), now.Month(), now.Day()+day, 0, 0, 0, 0, location)
+			start := midnight.Add(window.start)
+			end := midnight.Add(window.end)
+			if window.end <= window.start {
+				end = end.AddDate(0, 0, 1)
+			}
+			if !now.Before(start) && now.Before(end) {
+				return 0, nil
+			}
+			if start.After(now) && (wait < 0 || start.Sub(now) < wait) {
+				wait = start.Sub(now)
+			}
+		}
+	}
+	return wait, nil
+}
+
+func validateUpdatePolicy(config v1alpha1.K8sGPT) []error {
+	policy := config.Spec.UpdatePolicy
+	if policy == nil {
+		return nil
+	}
+	var errs []error
+	if _, er := updatePolicyLocation(policy); er != nil {
+		errs = append(errs, er)
+	}
+	for _, allowedHours := range policy.AllowedHours {
+		if _, er := parseUpdateWindow(allowedHours); er != nil {
+			errs = append(errs, er)
+		}
+	}
+	return errs
+}
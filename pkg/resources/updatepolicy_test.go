@@ -0,0 +1,81 @@
+package resources
+
+import (
+	"testing"
+	"time"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NextUpdateWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2023, time.October, 10, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name   string
+		policy *v1alpha1.UpdatePolicySpec
+		now    time.Time
+		want   time.Duration
+	}{
+		{name: "no policy", policy: nil, now: at(12, 0), want: 0},
+		{
+			name:   "inside the window",
+			policy: &v1alpha1.UpdatePolicySpec{AllowedHours: []string{"02:00-04:00"}},
+			now:    at(3, 0),
+			want:   0,
+		},
+		{
+			name:   "before the window",
+			policy: &v1alpha1.UpdatePolicySpec{AllowedHours: []string{"02:00-04:00"}},
+			now:    at(1, 30),
+			want:   30 * time.Minute,
+		},
+		{
+			name:   "after the window",
+			policy: &v1alpha1.UpdatePolicySpec{AllowedHours: []string{"02:00-04:00"}},
+			now:    at(4, 0),
+			want:   22 * time.Hour,
+		},
+		{
+			name:   "the next of several windows",
+			policy: &v1alpha1.UpdatePolicySpec{AllowedHours: []string{"02:00-04:00", "13:00-14:00"}},
+			now:    at(12, 0),
+			want:   time.Hour,
+		},
+		{
+			name:   "spanning midnight",
+			policy: &v1alpha1.UpdatePolicySpec{AllowedHours: []string{"23:00-01:00"}},
+			now:    at(0, 30),
+			want:   0,
+		},
+		{
+			name: "in another time zone",
+			policy: &v1alpha1.UpdatePolicySpec{
+				AllowedHours: []string{"02:00-04:00"},
+				Timezone:     "Europe/Berlin",
+			},
+			// 03:00 in Berlin during summer time
+			now:  at(1, 0),
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, err := NextUpdateWindow(tt.policy, tt.now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, wait)
+		})
+	}
+}
+
+func Test_ValidateConfigUpdatePolicy(t *testing.T) {
+	errs := ValidateConfig(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.UpdatePolicy = &v1alpha1.UpdatePolicySpec{
+			AllowedHours: []string{"02:00-04:00", "25:00-26:00", "02:00"},
+			Timezone:     "Mars/Olympus_Mons",
+		}
+	}))
+	assert.Len(t, errs, 3)
+}
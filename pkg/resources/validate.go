@@ -0,0 +1,368 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package resources
+
+import (
+	err "errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/k8sgpt-ai/k8sgpt-operator/pkg/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+var pluginNameRegexp = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// ValidateConfig runs every check of the K8sGPT spec, so that all problems
+// are reported at once instead of one per sync
+func ValidateConfig(config v1alpha1.K8sGPT) []error {
+	var errs []error
+	errs = append(errs, validateService(config)...)
+	errs = append(errs, validateDeployment(config)...)
+	errs = append(errs, validateNetworkPolicy(config)...)
+	errs = append(errs, validateUpdatePolicy(config)...)
+	errs = append(errs, validateNotifications(config)...)
+	return errs
+}
+
+func validateNotifications(config v1alpha1.K8sGPT) []error {
+	var errs []error
+	if config.Spec.Notifications == nil || config.Spec.Notifications.Slack == nil {
+		return errs
+	}
+	webhookURL := config.Spec.Notifications.Slack.WebhookURLSecretRef
+	if webhookURL.Name == "" || webhookURL.Key == "" {
+		errs = append(errs, err.New("WebhookURLSecretRef requires the name and key of the secret."))
+	}
+	return errs
+}
+
+func validateService(config v1alpha1.K8sGPT) []error {
+	var errs []error
+	if config.Spec.SessionAffinityConfig != nil && config.Spec.SessionAffinity != corev1.ServiceAffinityClientIP {
+		errs = append(errs, err.New("SessionAffinityConfig requires the ClientIP session affinity."))
+	}
+	if servicePort := config.Spec.ServicePort; servicePort != nil {
+		if servicePort.Port < 0 || servicePort.Port > 65535 {
+			errs = append(errs, fmt.Errorf("%d is not a valid port.", servicePort.Port))
+		}
+		if servicePort.TargetPort.Type == intstr.Int &&
+			(servicePort.TargetPort.IntVal < 0 || servicePort.TargetPort.IntVal > 65535) {
+			errs = append(errs, fmt.Errorf("%d is not a valid port.", servicePort.TargetPort.IntVal))
+		}
+		// a named port is the name of the container port
+		if servicePort.TargetPort.Type == intstr.String && servicePort.TargetPort.StrVal != "" {
+			for _, msg := range validation.IsValidPortName(servicePort.TargetPort.StrVal) {
+				errs = append(errs, fmt.Errorf("TargetPort %s is not a valid port name: %s.", servicePort.TargetPort.StrVal, msg))
+			}
+		}
+	}
+	return errs
+}
+
+func validateNetworkPolicy(config v1alpha1.K8sGPT) []error {
+	if config.Spec.EgressPolicy == nil {
+		return nil
+	}
+	var errs []error
+	// without CIDRs all egress besides DNS is denied, the ports would not apply to anything
+	if len(config.Spec.EgressPolicy.AllowedCIDRs) == 0 {
+		if len(config.Spec.EgressPolicy.AllowedPorts) > 0 {
+			errs = append(errs, err.New("AllowedPorts require AllowedCIDRs."))
+		} else {
+			errs = append(errs, err.New("EgressPolicy requires AllowedCIDRs, including the Kubernetes API server."))
+		}
+	}
+	for _, cidr := range config.Spec.EgressPolicy.AllowedCIDRs {
+		if _, _, er := net.ParseCIDR(cidr); er != nil {
+			errs = append(errs, fmt.Errorf("%s is not a valid CIDR.", cidr))
+		}
+	}
+	for _, port := range config.Spec.EgressPolicy.AllowedPorts {
+		if port < 1 || port > 65535 {
+			errs = append(errs, fmt.Errorf("%d is not a valid port.", port))
+		}
+	}
+	return errs
+}
+
+func validateDeployment(config v1alpha1.K8sGPT) []error {
+	var errs []error
+	if remoteCache := config.Spec.RemoteCache; remoteCache != nil {
+		backends := 0
+		for _, set := range []bool{
+			remoteCache.Azure != nil,
+			remoteCache.S3 != nil,
+			remoteCache.GCS != nil,
+			remoteCache.Redis != nil,
+		} {
+			if set {
+				backends++
+			}
+		}
+		if backends > 1 {
+			errs = append(errs, err.New("Only one of azure, s3, gcs or redis can be set as remote cache."))
+		}
+		// k8sgpt takes whole seconds
+		if remoteCache.TTL != nil && remoteCache.TTL.Duration < time.Second {
+			errs = append(errs, err.New("Remote cache TTL must be at least 1s."))
+		}
+		if remoteCache.Azure != nil {
+			// the names are passed to k8sgpt with the AddConfig call
+			if remoteCache.Azure.StorageAccount == "" || remoteCache.Azure.ContainerName == "" {
+				errs = append(errs, err.New("StorageAccount and ContainerName are required by the azure remote cache."))
+			}
+			if remoteCache.Azure.BlobEndpoint != "" {
+				endpoint, er := url.Parse(remoteCache.Azure.BlobEndpoint)
+				if er != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+					errs = append(errs, err.New("Azure BlobEndpoint must be a valid https URL."))
+				}
+			}
+		} else if remoteCache.S3 != nil {
+			if !s3BucketNameRegexp.MatchString(remoteCache.S3.BucketName) {
+				errs = append(errs, err.New("S3 BucketName must be 3-63 lowercase letters, digits or hyphens."))
+			}
+			if remoteCache.S3.Endpoint != "" {
+				endpoint, er := url.Parse(remoteCache.S3.Endpoint)
+				if er != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+					errs = append(errs, err.New("S3 Endpoint must be a valid http or https URL."))
+				}
+			}
+		}
+	}
+
+	if observability := config.Spec.Observability; observability != nil {
+		if observability.OTLPEndpoint != "" {
+			endpoint, er := url.Parse(observability.OTLPEndpoint)
+			if er != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+				errs = append(errs, err.New("OTLPEndpoint must be a valid http or https URL."))
+			}
+		}
+		if len(observability.OTLPHeaders) > 0 && observability.OTLPHeadersSecretRef != nil {
+			errs = append(errs, err.New("Only one of OTLPHeaders or OTLPHeadersSecretRef can be set."))
+		}
+	}
+	if config.Spec.GRPCMaxMessageSizeMB != 0 &&
+		(config.Spec.GRPCMaxMessageSizeMB < 1 || config.Spec.GRPCMaxMessageSizeMB > 512) {
+		errs = append(errs, err.New("GRPCMaxMessageSizeMB must be between 1 and 512."))
+	}
+	if config.Spec.RuntimeTuning != nil && config.Spec.RuntimeTuning.GoGC != nil &&
+		(*config.Spec.RuntimeTuning.GoGC < 10 || *config.Spec.RuntimeTuning.GoGC > 1000) {
+		errs = append(errs, err.New("GoGC must be between 10 and 1000."))
+	}
+	if config.Spec.ScheduledAnalysis != nil && strings.TrimSpace(config.Spec.ScheduledAnalysis.Schedule) == "" {
+		errs = append(errs, err.New("Schedule is required by ScheduledAnalysis."))
+	}
+	if config.Spec.WorkingDir != "" && !strings.HasPrefix(config.Spec.WorkingDir, "/") {
+		errs = append(errs, err.New("WorkingDir must be an absolute path."))
+	}
+	// an empty namespace would widen the analysis to the whole cluster
+	for _, namespace := range config.Spec.WatchedNamespaces {
+		if msgs := validation.IsDNS1123Label(namespace); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("WatchedNamespaces %q is not a valid namespace: %s.",
+				namespace, strings.Join(msgs, ", ")))
+		}
+	}
+	if config.Spec.DNSPolicy == corev1.DNSNone &&
+		(config.Spec.DNSConfig == nil || len(config.Spec.DNSConfig.Nameservers) == 0) {
+		errs = append(errs, err.New("DNSConfig with nameservers is required by the None DNS policy."))
+	}
+	if config.Spec.RevisionHistoryLimit != nil && *config.Spec.RevisionHistoryLimit < 0 {
+		errs = append(errs, err.New("RevisionHistoryLimit must not be negative."))
+	}
+	if config.Spec.ProgressDeadlineSeconds != nil &&
+		*config.Spec.ProgressDeadlineSeconds <= config.Spec.MinReadySeconds {
+		errs = append(errs, err.New("ProgressDeadlineSeconds must be greater than MinReadySeconds."))
+	}
+	if (config.Spec.MaxSurge != nil || config.Spec.MaxUnavailable != nil) &&
+		config.Spec.UpdateStrategy.Type == appsv1.RecreateDeploymentStrategyType {
+		errs = append(errs, err.New("MaxSurge and MaxUnavailable are supported only by the RollingUpdate strategy."))
+	}
+	// the liveness probe must not restart k8sgpt before it could have started
+	if startup, liveness := config.Spec.StartupProbe, config.Spec.LivenessProbe; startup != nil && liveness != nil &&
+		probeFailureThreshold(startup)*probePeriodSeconds(startup) <= liveness.InitialDelaySeconds {
+		errs = append(errs, err.New("StartupProbe FailureThreshold times PeriodSeconds must be greater than the LivenessProbe InitialDelaySeconds."))
+	}
+	if config.Spec.RemoteKubeconfig != nil && config.Spec.RemoteKubeconfig.Namespace != "" &&
+		config.Spec.RemoteKubeconfig.Namespace != GetTargetNamespace(config) {
+		errs = append(errs, err.New("RemoteKubeconfig must be in the namespace of the deployment."))
+	}
+
+	if config.Spec.AI == nil {
+		return errs
+	}
+	ai := config.Spec.AI
+	if ai.ExtraHeadersSecretRef != nil && ai.ExtraHeadersSecretRef.Namespace != "" &&
+		ai.ExtraHeadersSecretRef.Namespace != GetTargetNamespace(config) {
+		errs = append(errs, err.New("ExtraHeadersSecretRef must be in the namespace of the deployment."))
+	}
+	// legitimate analyses take several seconds
+	if ai.ResponseTimeout != nil && ai.ResponseTimeout.Duration < 5*time.Second {
+		errs = append(errs, err.New("ResponseTimeout must be at least 5s."))
+	}
+	if ai.LocalModelPath != "" {
+		if !strings.HasPrefix(ai.LocalModelPath, "/") {
+			errs = append(errs, err.New("LocalModelPath must be an absolute path."))
+		} else if ai.LocalModelClaimName != "" && path.Dir(ai.LocalModelPath) == "/" {
+			errs = append(errs, err.New("LocalModelPath must be in a directory to mount LocalModelClaimName."))
+		}
+	} else if ai.LocalModelClaimName != "" {
+		errs = append(errs, err.New("LocalModelPath is required by LocalModelClaimName."))
+	}
+	plugins := map[string]bool{}
+	for _, plugin := range ai.Plugins {
+		if !pluginNameRegexp.MatchString(plugin.Name) {
+			errs = append(errs, fmt.Errorf("Plugin name %q must contain only letters, digits and underscores.", plugin.Name))
+		}
+		// the names are case insensitive in the environment variables
+		if plugins[strings.ToUpper(plugin.Name)] {
+			errs = append(errs, fmt.Errorf("Plugin %s is configured more than once.", plugin.Name))
+		}
+		plugins[strings.ToUpper(plugin.Name)] = true
+	}
+	for analyzer := range ai.ModelOverridePerAnalyzer {
+		if !utils.ContainsString(Analyzers, analyzer) {
+			errs = append(errs, fmt.Errorf("%s is not a known analyzer.", analyzer))
+		}
+	}
+	if ai.ContextWindow != 0 && (ai.ContextWindow < 1000 || ai.ContextWindow > 200000) {
+		errs = append(errs, err.New("ContextWindow must be between 1000 and 200000."))
+	}
+	if ai.FunctionCalling && !utils.ContainsString(v1alpha1.FunctionCallingBackends, ai.Backend) {
+		errs = append(errs, fmt.Errorf("FunctionCalling is supported only by %s providers.",
+			strings.Join(v1alpha1.FunctionCallingBackends, ", ")))
+	}
+	// the slack sink posts the details as mrkdwn
+	if ai.OutputFormat == "json" && config.Spec.Sink != nil && config.Spec.Sink.Type == "slack" {
+		errs = append(errs, err.New("OutputFormat json is not supported by the slack sink."))
+	}
+	if ai.TopP != nil {
+		if topP, er := strconv.ParseFloat(*ai.TopP, 64); er != nil || topP < 0 || topP > 1 {
+			errs = append(errs, err.New("TopP must be between 0.0 and 1.0."))
+		}
+	}
+	if ai.BatchSize != 0 && (ai.BatchSize < 1 || ai.BatchSize > 100) {
+		errs = append(errs, err.New("BatchSize must be between 1 and 100."))
+	}
+	for _, backend := range ai.BackendFallback {
+		if string(backend) == ai.Backend {
+			errs = append(errs, err.New("BackendFallback must not repeat the backend."))
+		}
+		// the secret is the only credential the k8sgpt deployment gets
+		if backendRequiresSecret(string(backend)) && ai.Secret == nil {
+			errs = append(errs, fmt.Errorf("BackendFallback %s requires a secret.", backend))
+		}
+	}
+	if ai.RetryPolicy != nil {
+		if ai.RetryPolicy.MaxRetries < 0 {
+			errs = append(errs, err.New("MaxRetries must not be negative."))
+		}
+		if ai.RetryPolicy.RetryDelay != nil && ai.RetryPolicy.RetryDelay.Duration <= 0 {
+			errs = append(errs, err.New("RetryDelay must be greater than zero."))
+		}
+	}
+	if ai.PromptTemplate != "" && !strings.Contains(ai.PromptTemplate, PromptTemplateResourceName) {
+		errs = append(errs, fmt.Errorf("PromptTemplate must contain %s.", PromptTemplateResourceName))
+	}
+	if config.Spec.NoCache && ai.CacheResults {
+		errs = append(errs, err.New("Only one of NoCache or CacheResults can be set."))
+	}
+	if ai.BaseUrl != "" && ai.BaseUrlSecretRef != nil {
+		errs = append(errs, err.New("Only one of BaseUrl or BaseUrlSecretRef can be set."))
+	}
+	// Anthropic has no default model
+	if ai.Backend == v1alpha1.Anthropic && ai.Model == "" {
+		errs = append(errs, err.New("Model is required by anthropic provider."))
+	}
+	// only the OpenAI reasoning models accept a reasoning effort
+	if ai.ReasoningEffort != "" && ai.Backend != v1alpha1.OpenAI && ai.Backend != v1alpha1.AzureOpenAI {
+		errs = append(errs, err.New("ReasoningEffort is supported only by openai, azureopenai providers."))
+	}
+	if ai.Backend == v1alpha1.Groq && ai.Secret == nil {
+		errs = append(errs, err.New("Secret is required by groq provider."))
+	}
+	if ai.Backend == v1alpha1.Mistral && ai.Secret == nil {
+		errs = append(errs, err.New("Secret is required by mistral provider."))
+	}
+	// Engine, APIVersion and AzureAD are used only when azureopenai is the ai backend
+	if ai.Backend == v1alpha1.AzureOpenAI {
+		if ai.AzureAD != nil {
+			if ai.AzureAD.TenantID == "" || ai.AzureAD.ClientID == "" {
+				errs = append(errs, err.New("AzureAD requires TenantID and ClientID."))
+			}
+			if ai.Secret != nil {
+				errs = append(errs, err.New("Only one of Secret or AzureAD can be set."))
+			}
+			// the azure remote cache configures the same variables
+			if config.Spec.RemoteCache != nil && config.Spec.RemoteCache.Azure != nil {
+				errs = append(errs, err.New("AzureAD cannot be combined with the azure remote cache."))
+			}
+		}
+	} else {
+		if ai.Engine != "" {
+			errs = append(errs, err.New("Engine is supported only by azureopenai provider."))
+		}
+		if ai.APIVersion != "" {
+			errs = append(errs, err.New("APIVersion is supported only by azureopenai provider."))
+		}
+		if ai.AzureAD != nil {
+			errs = append(errs, err.New("AzureAD is supported only by azureopenai provider."))
+		}
+	}
+	return errs
+}
+
+// probePeriodSeconds returns the period of the probe, 10 seconds when unset like in Kubernetes
+func probePeriodSeconds(probe *corev1.Probe) int32 {
+	if probe.PeriodSeconds == 0 {
+		return 10
+	}
+	return probe.PeriodSeconds
+}
+
+// probeFailureThreshold returns the failure threshold of the probe, 3 when unset like in Kubernetes
+func probeFailureThreshold(probe *corev1.Probe) int32 {
+	if probe.FailureThreshold == 0 {
+		return 3
+	}
+	return probe.FailureThreshold
+}
+
+// ValidateBackendChange rejects switching the backend of the deployed k8sgpt,
+// unless it is allowed with the annotation. The webhook rejects the change
+// already, this catches it when the webhook is not installed.
+func ValidateBackendChange(deployment *appsv1.Deployment, config v1alpha1.K8sGPT) error {
+	if config.Spec.AI == nil || len(deployment.Spec.Template.Spec.Containers) == 0 ||
+		config.Annotations[v1alpha1.AllowBackendChangeAnnotation] == "true" {
+		return nil
+	}
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "K8SGPT_BACKEND" && env.Value != config.Spec.AI.Backend {
+			return fmt.Errorf("Backend cannot be changed from %s to %s, set the %s: \"true\" annotation to allow it.",
+				env.Value, config.Spec.AI.Backend, v1alpha1.AllowBackendChangeAnnotation)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_ValidateConfig(t *testing.T) {
+	assert.Empty(t, ValidateConfig(newTestConfig(nil)))
+
+	errs := ValidateConfig(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.Engine = "llm"
+		c.Spec.AI.BatchSize = 200
+		c.Spec.WorkingDir = "tmp"
+		c.Spec.SessionAffinityConfig = &v1.SessionAffinityConfig{}
+		c.Spec.EgressPolicy = &v1alpha1.EgressPolicySpec{AllowedPorts: []int32{0}}
+	}))
+	var messages []string
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+	assert.ElementsMatch(t, []string{
+		"Engine is supported only by azureopenai provider.",
+		"BatchSize must be between 1 and 100.",
+		"WorkingDir must be an absolute path.",
+		"SessionAffinityConfig requires the ClientIP session affinity.",
+		"AllowedPorts require AllowedCIDRs.",
+		"0 is not a valid port.",
+	}, messages)
+}
+
+func Test_SyncShouldReportAllValidationErrors(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+	ctx := context.Background()
+	config := newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.Engine = "llm"
+		c.Spec.AI.BatchSize = 200
+	})
+
+	err := Sync(ctx, fakeClient, config, SyncOp)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Engine is supported only by azureopenai provider.")
+	assert.Contains(t, err.Error(), "BatchSize must be between 1 and 100.")
+
+	// nothing is created for an invalid spec
+	deployments := &appsv1.DeploymentList{}
+	require.NoError(t, fakeClient.List(ctx, deployments))
+	assert.Empty(t, deployments.Items)
+}
+
+func Test_ValidateConfigWatchedNamespaces(t *testing.T) {
+	assert.Empty(t, ValidateConfig(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.WatchedNamespaces = []string{"team-a", "team-b"}
+	})))
+
+	errs := ValidateConfig(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.WatchedNamespaces = []string{"team-a", "", "Team_B"}
+	}))
+	assert.Len(t, errs, 2)
+}
+
+func Test_ValidateConfigAI(t *testing.T) {
+	errs := ValidateConfig(newTestConfig(func(c *v1alpha1.K8sGPT) {
+		c.Spec.AI.Backend = v1alpha1.Anthropic
+		c.Spec.AI.Model = ""
+		c.Spec.AI.ReasoningEffort = "high"
+	}))
+	var messages []string
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+	assert.ElementsMatch(t, []string{
+		"Model is required by anthropic provider.",
+		"ReasoningEffort is supported only by openai, azureopenai providers.",
+	}, messages)
+}
+
+func Test_ValidateBackendChange(t *testing.T) {
+	config := newTestConfig(nil)
+	deployment, err := GetDeployment(config)
+	require.NoError(t, err)
+	assert.NoError(t, ValidateBackendChange(deployment, config))
+
+	config.Spec.AI.Backend = v1alpha1.LocalAI
+	assert.EqualError(t, ValidateBackendChange(deployment, config),
+		"Backend cannot be changed from openai to localai, set the k8sgpt.ai/allow-backend-change: \"true\" annotation to allow it.")
+
+	config.Annotations = map[string]string{v1alpha1.AllowBackendChangeAnnotation: "true"}
+	assert.NoError(t, ValidateBackendChange(deployment, config))
+}
@@ -19,6 +19,7 @@ type SlackSink struct {
 
 type SlackMessage struct {
 	Text        string       `json:"text"`
+	Channel     string       `json:"channel,omitempty"`
 	Attachments []Attachment `json:"attachments"`
 }
 
@@ -52,17 +53,38 @@ func (s *SlackSink) Configure(config v1alpha1.K8sGPT, c Client) {
 
 func (s *SlackSink) Emit(results v1alpha1.ResultSpec) error {
 	message := buildSlackMessage(results.Kind, results.Name, results.Details, s.K8sGPT)
+	return postSlackMessage(s.Client, s.Endpoint, message)
+}
+
+// NotifyDegraded posts to a slack incoming webhook why the K8sGPT Custom Resource is degraded
+func NotifyDegraded(c Client, webhookURL, channel, k8sgptCR, reason string) error {
+	message := SlackMessage{
+		Text:    fmt.Sprintf(">*[%s] K8sGPT is degraded*", k8sgptCR),
+		Channel: channel,
+		Attachments: []Attachment{
+			{
+				Type:  "mrkdwn",
+				Text:  reason,
+				Color: "danger",
+				Title: "Reason",
+			},
+		},
+	}
+	return postSlackMessage(c, webhookURL, message)
+}
+
+func postSlackMessage(c Client, endpoint string, message SlackMessage) error {
 	payload, err := json.Marshal(message)
 	if err != nil {
 		return err
 	}
-	req, err := http.NewRequest(http.MethodPost, s.Endpoint, bytes.NewBuffer(payload))
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewBuffer(payload))
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	resp, err := s.Client.hclient.Do(req)
+	resp, err := c.hclient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,157 @@
+/*
+Copyright 2023 The K8sGPT Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const Path = "/api/v1/summary"
+
+// InstanceSummary summarizes the findings of a single K8sGPT instance
+type InstanceSummary struct {
+	IssueCount int `json:"issueCount"`
+	// LastAnalysis is the last time the analysis created or changed one of
+	// the results, unchanged results are not written again
+	LastAnalysis *metav1.Time `json:"lastAnalysis,omitempty"`
+}
+
+// Handler serves the findings of all K8sGPT instances, keyed by namespace/name.
+// Callers authenticate with a bearer token and need get on k8sgpts.core.k8sgpt.ai.
+type Handler struct {
+	Client client.Client
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	allowed, err := Authorize(r.Context(), h.Client, r, &authorizationv1.ResourceAttributes{
+		Group:    v1alpha1.GroupVersion.Group,
+		Resource: "k8sgpts",
+		Verb:     "get",
+	})
+	if err != nil {
+		fmt.Printf("Error authorizing summary request: %v\n", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if !allowed {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	summary, err := h.summarize(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		fmt.Printf("Error writing summary: %v\n", err)
+	}
+}
+
+// Authorize reviews the bearer token of the request and checks the resulting
+// user is allowed the given resource attributes
+func Authorize(ctx context.Context, c client.Client, r *http.Request, attributes *authorizationv1.ResourceAttributes) (bool, error) {
+	header := r.Header.Get("Authorization")
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == header || token == "" {
+		return false, fmt.Errorf("missing bearer token")
+	}
+
+	tokenReview := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token},
+	}
+	if err := c.Create(ctx, tokenReview); err != nil {
+		return false, err
+	}
+	if !tokenReview.Status.Authenticated {
+		return false, fmt.Errorf("token is not authenticated: %s", tokenReview.Status.Error)
+	}
+
+	user := tokenReview.Status.User
+	extra := make(map[string]authorizationv1.ExtraValue, len(user.Extra))
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	accessReview := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:               user.Username,
+			UID:                user.UID,
+			Groups:             user.Groups,
+			Extra:              extra,
+			ResourceAttributes: attributes,
+		},
+	}
+	if err := c.Create(ctx, accessReview); err != nil {
+		return false, err
+	}
+
+	return accessReview.Status.Allowed, nil
+}
+
+func (h *Handler) summarize(ctx context.Context) (map[string]InstanceSummary, error) {
+	k8sgptList := &v1alpha1.K8sGPTList{}
+	if err := h.Client.List(ctx, k8sgptList); err != nil {
+		return nil, err
+	}
+	summary := make(map[string]InstanceSummary, len(k8sgptList.Items))
+	for _, k8sgpt := range k8sgptList.Items {
+		summary[k8sgpt.Namespace+"/"+k8sgpt.Name] = InstanceSummary{}
+	}
+
+	resultList := &v1alpha1.ResultList{}
+	if err := h.Client.List(ctx, resultList); err != nil {
+		return nil, err
+	}
+	for _, result := range resultList.Items {
+		key := result.Labels[v1alpha1.K8sGPTNamespaceLabel] + "/" + result.Labels[v1alpha1.K8sGPTNameLabel]
+		instance, ok := summary[key]
+		if !ok {
+			continue
+		}
+		instance.IssueCount++
+		if changed := lastChange(result); instance.LastAnalysis == nil || instance.LastAnalysis.Before(&changed) {
+			instance.LastAnalysis = &changed
+		}
+		summary[key] = instance
+	}
+
+	return summary, nil
+}
+
+// lastChange is when the result was created or last written to
+func lastChange(result v1alpha1.Result) metav1.Time {
+	changed := result.CreationTimestamp
+	for _, entry := range result.ManagedFields {
+		if entry.Time != nil && changed.Before(entry.Time) {
+			changed = *entry.Time
+		}
+	}
+	return changed
+}
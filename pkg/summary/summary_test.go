@@ -0,0 +1,99 @@
+package summary
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/k8sgpt-ai/k8sgpt-operator/api/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func Test_SummaryHandler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	k8sgpt := &v1alpha1.K8sGPT{
+		ObjectMeta: metav1.ObjectMeta{Name: "k8sgpt-sample", Namespace: "default"},
+	}
+	created := metav1.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := metav1.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	result := &v1alpha1.Result{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "defaultfoo",
+			Namespace:         "default",
+			CreationTimestamp: created,
+			Labels: map[string]string{
+				v1alpha1.K8sGPTNameLabel:      "k8sgpt-sample",
+				v1alpha1.K8sGPTNamespaceLabel: "default",
+			},
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				{Manager: "manager", Operation: metav1.ManagedFieldsOperationUpdate, Time: &created},
+				{Manager: "manager", Operation: metav1.ManagedFieldsOperationUpdate, Time: &updated, Subresource: "status"},
+			},
+		},
+	}
+	// behave like the API server, only the admin token may get K8sGPT objects
+	fakeClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(k8sgpt, result).WithInterceptorFuncs(interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			switch review := obj.(type) {
+			case *authenticationv1.TokenReview:
+				review.Status.Authenticated = review.Spec.Token != "invalid"
+				review.Status.User.Username = review.Spec.Token
+			case *authorizationv1.SubjectAccessReview:
+				review.Status.Allowed = review.Spec.User == "admin"
+			default:
+				return c.Create(ctx, obj, opts...)
+			}
+			return nil
+		},
+	}).Build()
+	handler := &Handler{Client: fakeClient}
+
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "missing token", wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", token: "invalid", wantStatus: http.StatusUnauthorized},
+		{name: "not allowed", token: "viewer", wantStatus: http.StatusForbidden},
+		{name: "allowed", token: "admin", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, Path, nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+
+			handler.ServeHTTP(rec, req)
+			require.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			summary := map[string]InstanceSummary{}
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &summary))
+			require.Contains(t, summary, "default/k8sgpt-sample")
+			instance := summary["default/k8sgpt-sample"]
+			assert.Equal(t, 1, instance.IssueCount)
+			require.NotNil(t, instance.LastAnalysis)
+			assert.True(t, updated.Equal(instance.LastAnalysis))
+		})
+	}
+}